@@ -0,0 +1,235 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// annotationNames は、Config.Annotations に指定できる値です。
+var annotationNames = []string{"Keys", "Default", "Identity", "Generated", "Indexes", "View", "Lineage", "Routine", "Object", "Statistics", "Profile", "Classification"}
+
+// Annotate は、annotations で指定された情報を説明に追記した Metadata を返します。
+// Mashu CSV のように、構造化した情報を持てない出力形式で使います。
+//   - Keys: カラムが属する制約の名前と、外部キーの参照先
+//   - Default: カラムの既定値
+//   - Identity: 識別カラムの GENERATED 句
+//   - Generated: 生成カラムの GENERATED 句
+//   - Indexes: カラムを含む索引の名前
+//   - View: ビューが参照するテーブル(メタデータの説明に追記)
+//   - Lineage: ビューのカラムの元になった実表のカラム
+//   - Routine: ルーチンの種類と言語(メタデータの説明に追記)、パラメーターの種別
+//   - Object: 別名の参照先、ニックネームのリモートのオブジェクト、シーケンスの属性
+//     (メタデータの説明に追記)
+//   - Statistics: 行数、ページ数などの統計情報(メタデータの説明に追記)、
+//     カラムの異なる値の数、NULL の数などの統計情報
+//   - Profile: 標本のデータから作ったカラムのプロファイル
+//   - Classification: カラムの個人情報などの分類と確信度
+func (m Metadata) Annotate(annotations []string) Metadata {
+	if len(annotations) == 0 {
+		return m
+	}
+	columns := make([]Column, len(m.Columns))
+	copy(columns, m.Columns)
+	m.Columns = columns
+
+	for _, str := range annotations {
+		switch str {
+		case "Keys":
+			m.annotateKeys()
+		case "Default", "Identity", "Generated":
+			m.annotateColumns(str)
+		case "Indexes":
+			m.annotateIndexes()
+		case "Lineage":
+			m.annotateLineage()
+		case "Routine":
+			m.annotateRoutine()
+		case "Object":
+			m.annotateObject()
+		case "Statistics":
+			if m.Statistics != nil {
+				for _, note := range m.Statistics.Notes() {
+					m.Description = appendNote(m.Description, note)
+				}
+			}
+			m.annotateStatistics()
+		case "Profile":
+			m.annotateProfile()
+		case "Classification":
+			for i := range m.Columns {
+				col := &m.Columns[i]
+				if col.Classification != nil {
+					col.Description = appendNote(col.Description,
+						"Classification: "+col.Classification.String())
+				}
+			}
+		case "View":
+			if m.View != nil && len(m.View.BaseTables) > 0 {
+				m.Description = appendNote(m.Description,
+					"Base Tables: "+strings.Join(m.View.BaseTables, ", "))
+			}
+		}
+	}
+	return m
+}
+
+// annotateKeys は、制約の情報をカラムの説明に追記します。
+func (m *Metadata) annotateKeys() {
+	for _, c := range m.Constraints {
+		for i, name := range c.Columns {
+			col := m.column(name)
+			if col == nil {
+				continue
+			}
+			note := fmt.Sprintf("%s: %s", c.TypeName(), c.Name)
+			if c.RefTable != "" && i < len(c.RefColumns) {
+				note += fmt.Sprintf(" -> %s.%s", c.RefTable, c.RefColumns[i])
+			}
+			col.Description = appendNote(col.Description, note)
+		}
+	}
+}
+
+// annotateColumns は、カラムの既定値、識別カラム、生成カラムの情報を説明に追記します。
+func (m *Metadata) annotateColumns(annotation string) {
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		note := ""
+		switch {
+		case annotation == "Default" && col.Default != "":
+			note = col.Default
+		case annotation == "Identity" && col.Identity != nil:
+			note = col.GenerationClause()
+		case annotation == "Generated" && col.Generated != nil:
+			note = col.GenerationClause()
+		}
+		if note != "" {
+			col.Description = appendNote(col.Description, annotation+": "+note)
+		}
+	}
+}
+
+// annotateIndexes は、カラムを含む索引の名前を説明に追記します。
+func (m *Metadata) annotateIndexes() {
+	for _, idx := range m.Indexes {
+		label := "Index"
+		if idx.Unique {
+			label = "Unique Index"
+		}
+		for _, c := range idx.Columns {
+			if col := m.column(c.Name); col != nil {
+				col.Description = appendNote(col.Description,
+					fmt.Sprintf("%s: %s", label, idx.Name))
+			}
+		}
+		for _, name := range idx.Include {
+			if col := m.column(name); col != nil {
+				col.Description = appendNote(col.Description,
+					fmt.Sprintf("%s: %s (INCLUDE)", label, idx.Name))
+			}
+		}
+	}
+}
+
+// annotateLineage は、ビューのカラムの元になったカラムを説明に追記します。
+func (m *Metadata) annotateLineage() {
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		for _, s := range col.Lineage {
+			col.Description = appendNote(col.Description, "Source: "+s.String())
+		}
+	}
+}
+
+// annotateRoutine は、ルーチンの種類と言語、パラメーターの種別を説明に追記します。
+func (m *Metadata) annotateRoutine() {
+	if m.Routine == nil {
+		return
+	}
+	note := "Routine: " + m.Routine.Type
+	if m.Routine.Language != "" {
+		note += " (" + m.Routine.Language + ")"
+	}
+	m.Description = appendNote(m.Description, note)
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		if col.Parameter != "" {
+			col.Description = appendNote(col.Description, "Parameter: "+col.Parameter)
+		}
+	}
+}
+
+// annotateObject は、テーブル、ビュー、ルーチン以外のオブジェクトの情報を説明に追記します。
+func (m *Metadata) annotateObject() {
+	switch {
+	case m.BaseObject != "":
+		m.Description = appendNote(m.Description, "Alias for: "+m.BaseObject)
+	case m.Remote != nil:
+		m.Description = appendNote(m.Description, "Nickname for: "+m.Remote.String())
+	case m.Sequence != nil:
+		m.Description = appendNote(m.Description, "Sequence: "+m.Sequence.Clause())
+	}
+}
+
+// annotateStatistics は、カラムの統計情報を説明に追記します。
+func (m *Metadata) annotateStatistics() {
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		if col.Statistics == nil {
+			continue
+		}
+		for _, note := range col.Statistics.Notes() {
+			col.Description = appendNote(col.Description, note)
+		}
+	}
+}
+
+// annotateProfile は、カラムのプロファイルを説明に追記します。
+func (m *Metadata) annotateProfile() {
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		if col.Profile == nil {
+			continue
+		}
+		for _, note := range col.Profile.Notes() {
+			col.Description = appendNote(col.Description, note)
+		}
+	}
+}
+
+// column は、名前が一致する Column を返します。
+func (m *Metadata) column(name string) *Column {
+	for i := range m.Columns {
+		if m.Columns[i].Name == name {
+			return &m.Columns[i]
+		}
+	}
+	return nil
+}
+
+// appendNote は、説明に改行区切りで注記を追加します。
+func appendNote(description, note string) string {
+	if description == "" {
+		return note
+	}
+	return description + "\n" + note
+}
+
+// validAnnotation は、Config.Annotations に指定できる値かどうかを返します。
+func validAnnotation(str string) bool {
+	for _, name := range annotationNames {
+		if name == str {
+			return true
+		}
+	}
+	return false
+}
+
+// annotationList は、指定できる値の一覧を返します。
+func annotationList() string {
+	return strings.Join(annotationNames, ", ")
+}
@@ -0,0 +1,385 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// minConfidence は、Column.Classification に記録する確信度の下限です。
+const minConfidence = 0.5
+
+// 分類の根拠ごとの確信度です。値の確信度は、標本の値が一致した割合を掛けます。
+const (
+	confidenceNameDictionary = 0.9
+	confidenceNamePattern    = 0.6
+	confidenceText           = 0.7
+	confidenceValues         = 0.9
+)
+
+// Classification は、カラムに格納された個人情報などの分類です。
+type Classification struct {
+	// Category は、分類(Name, Phone, Email など)です。
+	Category string `json:"category"`
+	// Confidence は、0 から 1 の確信度です。
+	Confidence float64 `json:"confidence"`
+	// Evidence は、分類の根拠(name, alias, description, values)です。
+	Evidence []string `json:"evidence"`
+}
+
+// String は、分類と確信度を "Email (0.97)" の形式で返します。
+func (c Classification) String() string {
+	return fmt.Sprintf("%s (%s)", c.Category, strconv.FormatFloat(c.Confidence, 'f', -1, 64))
+}
+
+// ClassificationRule は、カラムを分類する規則です。いずれかの根拠が一致した
+// カラムを Category に分類します。
+type ClassificationRule struct {
+	// Category は、分類です。
+	Category string `json:"category"`
+	// Names は、カラム名の辞書です。大文字にして "_", "-", 空白を除いたカラム名と比較します。
+	Names []string `json:"names,omitempty"`
+	// NamePattern は、大文字にしたカラム名に一致させる正規表現です。
+	NamePattern string `json:"namePattern,omitempty"`
+	// TextPattern は、カラムの別名と説明に一致させる正規表現です。
+	TextPattern string `json:"textPattern,omitempty"`
+	// ValuePattern は、プロファイルの標本の値に一致させる正規表現です。
+	ValuePattern string `json:"valuePattern,omitempty"`
+	// Validator は、ValuePattern に一致した値の検査(luhn, mynumber)です。
+	Validator string `json:"validator,omitempty"`
+}
+
+// defaultClassificationRules は、組み込みの分類の規則です。カラム名の辞書は英語と
+// ローマ字、別名と説明の正規表現は漢字とカナの語を含みます。
+var defaultClassificationRules = []ClassificationRule{
+	{
+		Category: "Name",
+		// 商品名や部門名にも使う NAME だけのカラム名は、別名と説明で分類します
+		Names: []string{"FULLNAME", "FIRSTNAME", "LASTNAME", "FAMILYNAME", "GIVENNAME",
+			"MIDDLENAME", "NAMEKANA", "NAMEKANJI", "SHIMEI", "SIMEI", "SEI", "MEI", "NAMAE",
+			"FURIGANA", "SHIMEIKANA", "SEIKANA", "MEIKANA"},
+		NamePattern: `(^|_)(FIRST|LAST|FAMILY|GIVEN|FULL|MIDDLE)_?NA?ME($|_)|SHIMEI|SIMEI`,
+		TextPattern: `^(姓|名|氏|姓名|氏名|名前|フリガナ)$|氏名|名前|苗字|ふりがな|フリガナ`,
+	},
+	{
+		Category: "Phone",
+		Names: []string{"PHONE", "PHONENO", "PHONENUMBER", "TEL", "TELNO", "TELEPHONE", "MOBILE",
+			"FAX", "FAXNO", "DENWA", "DENWABANGO", "KEITAI", "KEITAIBANGO"},
+		NamePattern:  `PHONE|(^|_)TEL($|_|NO)|DENWA|KEITAI|MOBILE|(^|_)FAX($|_|NO)`,
+		TextPattern:  `電話|携帯|内線|(?i)fax|ファックス`,
+		ValuePattern: `^0\d{9,10}$|^(\+81[- ]?|0)\d{1,4}-\d{1,4}-\d{4}$`,
+	},
+	{
+		Category: "Email",
+		Names: []string{"EMAIL", "MAIL", "MAILADDRESS", "MAILADDR", "EMAILADDRESS", "EMAILADDR",
+			"MEERU", "MERUADO"},
+		NamePattern:  `(^|_)E?MAIL`,
+		TextPattern:  `メール|(?i)e-?mail`,
+		ValuePattern: `^[^@\s]+@[^@\s]+\.[A-Za-z]{2,}$`,
+	},
+	{
+		Category:     "MyNumber",
+		Names:        []string{"MYNUMBER", "MYNO", "KOJINBANGO", "KOJINNO"},
+		NamePattern:  `MY_?NUMBER|KOJIN_?BANGO`,
+		TextPattern:  `マイナンバー|個人番号`,
+		ValuePattern: `^\d{12}$`,
+		Validator:    "mynumber",
+	},
+	{
+		Category:     "CreditCard",
+		Names:        []string{"CARDNO", "CARDNUMBER", "CREDITCARD", "CREDITCARDNO", "CCNUMBER", "CCNO"},
+		NamePattern:  `CREDIT|CARD_?(NO|NUM)`,
+		TextPattern:  `クレジット|カード番号`,
+		ValuePattern: `^\d{4}[- ]?\d{4}[- ]?\d{4}[- ]?\d{1,7}$`,
+		Validator:    "luhn",
+	},
+	{
+		Category: "Address",
+		Names: []string{"ADDRESS", "ADDR", "ADDRESS1", "ADDRESS2", "JUSHO", "JUUSHO", "JYUSHO",
+			"ZIP", "ZIPCODE", "POSTCODE", "POSTALCODE", "YUBIN", "YUBINBANGO"},
+		NamePattern:  `ADDRESS|(^|_)ADDR($|_|\d)|JU+SHO|JYUSHO|ZIP|POSTAL|YUBIN`,
+		TextPattern:  `住所|所在地|郵便番号|〒`,
+		ValuePattern: `^〒?\d{3}-\d{4}$`,
+	},
+	{
+		Category:    "BirthDate",
+		Names:       []string{"BIRTHDATE", "BIRTHDAY", "DOB", "SEINENGAPPI", "TANJOBI", "BIRTHDT"},
+		NamePattern: `BIRTH|(^|_)DOB($|_)|SEINENGAPPI|TANJO`,
+		TextPattern: `生年月日|誕生日`,
+	},
+	{
+		Category:    "Gender",
+		Names:       []string{"SEX", "GENDER", "SEIBETSU", "SEIBETU"},
+		NamePattern: `GENDER|SEIBETS?U`,
+		TextPattern: `性別`,
+	},
+	{
+		Category:    "BankAccount",
+		Names:       []string{"ACCOUNTNO", "ACCOUNTNUMBER", "BANKACCOUNT", "KOZA", "KOUZA", "KOZABANGO"},
+		NamePattern: `BANK_?ACCOUNT|ACCOUNT_?(NO|NUM)|KOU?ZA`,
+		TextPattern: `口座`,
+	},
+}
+
+// LoadClassificationRules は、JSON 形式の分類の規則を読み込み、組み込みの規則と
+// まとめて返します。組み込みと同じ Category の規則は、組み込みの規則を置き換えます。
+// path が空の場合は、組み込みの規則を返します。
+func LoadClassificationRules(path string) ([]ClassificationRule, error) {
+	rules := make([]ClassificationRule, len(defaultClassificationRules))
+	copy(rules, defaultClassificationRules)
+	if path == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []ClassificationRule
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, rule := range list {
+		replaced := false
+		for i := range rules {
+			if rules[i].Category == rule.Category {
+				rules[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// classificationRule は、正規表現をコンパイルした ClassificationRule です。
+type classificationRule struct {
+	category     string
+	names        map[string]bool
+	namePattern  *regexp.Regexp
+	textPattern  *regexp.Regexp
+	valuePattern *regexp.Regexp
+	validate     func(str string) bool
+}
+
+// Classifier は、分類の規則でカラムを分類します。
+type Classifier struct {
+	rules []classificationRule
+}
+
+// NewClassifier は、rules の Classifier を作ります。
+func NewClassifier(rules []ClassificationRule) (*Classifier, error) {
+	c := &Classifier{}
+	for _, rule := range rules {
+		if rule.Category == "" {
+			return nil, errors.New("classification rule has no category")
+		}
+		r := classificationRule{category: rule.Category, names: make(map[string]bool)}
+		for _, name := range rule.Names {
+			r.names[normalizeColumnName(name)] = true
+		}
+		var err error
+		for _, p := range []struct {
+			pattern string
+			re      **regexp.Regexp
+		}{
+			{rule.NamePattern, &r.namePattern},
+			{rule.TextPattern, &r.textPattern},
+			{rule.ValuePattern, &r.valuePattern},
+		} {
+			if p.pattern == "" {
+				continue
+			}
+			*p.re, err = regexp.Compile(p.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("classification rule %s: %w", rule.Category, err)
+			}
+		}
+		switch rule.Validator {
+		case "":
+		case "luhn":
+			r.validate = validLuhn
+		case "mynumber":
+			r.validate = validMyNumber
+		default:
+			return nil, fmt.Errorf("classification rule %s: validator %q is not luhn or mynumber",
+				rule.Category, rule.Validator)
+		}
+		c.rules = append(c.rules, r)
+	}
+	return c, nil
+}
+
+// Classify は、カラムを分類します。確信度が minConfidence 未満の場合は nil を返します。
+// 確信度は、カラム名、別名と説明、標本の値の根拠ごとの確信度 c から 1 - Π(1 - c) で求めます。
+func (c *Classifier) Classify(col Column) *Classification {
+	var best *Classification
+	name := strings.ToUpper(col.Name)
+	for _, r := range c.rules {
+		result := &Classification{Category: r.category}
+		rest := 1.0
+		switch {
+		case r.names[normalizeColumnName(name)]:
+			rest *= 1 - confidenceNameDictionary
+			result.Evidence = append(result.Evidence, "name")
+		case r.namePattern != nil && r.namePattern.MatchString(name):
+			rest *= 1 - confidenceNamePattern
+			result.Evidence = append(result.Evidence, "name")
+		}
+		if r.textPattern != nil {
+			matched := false
+			for _, e := range []struct{ name, text string }{
+				{"alias", col.Alias}, {"description", col.Description},
+			} {
+				if e.text != "" && r.textPattern.MatchString(strings.TrimSpace(e.text)) {
+					matched = true
+					result.Evidence = append(result.Evidence, e.name)
+				}
+			}
+			// 別名と説明は同じ注釈のことが多いため、両方が一致しても 1 つの根拠とします
+			if matched {
+				rest *= 1 - confidenceText
+			}
+		}
+		if ratio := r.matchValues(col.samples); ratio > 0 {
+			rest *= 1 - confidenceValues*ratio
+			result.Evidence = append(result.Evidence, "values")
+		}
+		result.Confidence = math.Round((1-rest)*100) / 100
+		if result.Confidence >= minConfidence && (best == nil || result.Confidence > best.Confidence) {
+			best = result
+		}
+	}
+	return best
+}
+
+// matchValues は、標本の値のうち ValuePattern と検査に一致した割合を返します。
+// 一致した割合が 8 割に満たない場合は 0 を返します。
+func (r classificationRule) matchValues(samples []string) float64 {
+	if r.valuePattern == nil {
+		return 0
+	}
+	count, matched := 0, 0
+	for _, v := range samples {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		count++
+		if r.valuePattern.MatchString(v) && (r.validate == nil || r.validate(v)) {
+			matched++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	ratio := float64(matched) / float64(count)
+	if ratio < 0.8 {
+		return 0
+	}
+	return ratio
+}
+
+// normalizeColumnName は、辞書と比較するために、大文字にして "_", "-", 空白を除きます。
+func normalizeColumnName(name string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToUpper(name))
+}
+
+// validLuhn は、区切りを除いた数字が Luhn のチェックディジットを満たすかどうかを返します。
+func validLuhn(str string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(str)
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return len(digits) > 0 && sum%10 == 0
+}
+
+// validMyNumber は、12 桁の数字が個人番号のチェックディジットを満たすかどうかを返します。
+// 検査用数字は、11 - (Σ Pn × Qn を 11 で割った余り) で、余りが 1 以下の場合は 0 です。
+func validMyNumber(str string) bool {
+	if len(str) != 12 {
+		return false
+	}
+	sum := 0
+	for n := 1; n <= 11; n++ {
+		p := int(str[11-n] - '0')
+		if p < 0 || p > 9 {
+			return false
+		}
+		q := n + 1
+		if n >= 7 {
+			q = n - 5
+		}
+		sum += p * q
+	}
+	check := 0
+	if r := sum % 11; r > 1 {
+		check = 11 - r
+	}
+	return int(str[11]-'0') == check
+}
+
+// classifyMetadata は、Config.Classify が真の場合に、Metadata のカラムを分類する
+// ステージです。Config.ClassificationRules の規則を組み込みの規則に加えます。
+// 分類の後で、出力に残さない値をプロファイルとカラムの統計情報から取り除きます。
+func classifyMetadata(ctx context.Context, input <-chan MetadataInProcess,
+	config *Config) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		var c *Classifier
+		if config.Classify {
+			rules, err := LoadClassificationRules(config.ClassificationRules)
+			if err != nil {
+				return nil, err
+			}
+			c, err = NewClassifier(rules)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return func(meta *Metadata) {
+			for i := range meta.Columns {
+				col := &meta.Columns[i]
+				if c != nil {
+					col.Classification = c.Classify(*col)
+				}
+				col.samples = nil
+				// 個人情報の可能性がある標本と統計情報の値は、メタデータのカタログに複写しません
+				if !config.ProfileValues || col.Classification != nil {
+					if col.Profile != nil {
+						col.Profile.Min = ""
+						col.Profile.Max = ""
+						col.Profile.TopValues = nil
+					}
+					if col.Statistics != nil {
+						col.Statistics.High = ""
+						col.Statistics.Low = ""
+					}
+				}
+			}
+		}, nil
+	})
+}
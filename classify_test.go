@@ -0,0 +1,90 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClassifierClassify(t *testing.T) {
+	rules, err := LoadClassificationRules("")
+	if err != nil {
+		t.Fatalf("LoadClassificationRules() error :%s", err)
+	}
+	c, err := NewClassifier(rules)
+	if err != nil {
+		t.Fatalf("NewClassifier() error :%s", err)
+	}
+
+	tests := []struct {
+		name string
+		col  Column
+		want *Classification
+	}{
+		{"english dictionary", Column{Name: "FIRST_NAME"},
+			&Classification{"Name", 0.9, []string{"name"}}},
+		{"romaji dictionary and kanji", Column{Name: "DENWA_BANGO", Alias: "電話番号", Description: "電話番号"},
+			&Classification{"Phone", 0.97, []string{"name", "alias", "description"}}},
+		{"kanji only", Column{Name: "LASTNAME2", Alias: "姓"},
+			&Classification{"Name", 0.7, []string{"alias"}}},
+		{"phone values", Column{Name: "CONTACT", samples: []string{"03-1234-5678", "09012345678"}},
+			&Classification{"Phone", 0.9, []string{"values"}}},
+		{"short numbers", Column{Name: "CODE", samples: []string{"000010", "0972"}}, nil},
+		{"values", Column{Name: "CONTACT", samples: []string{"a@example.com", "b@example.jp", " "}},
+			&Classification{"Email", 0.9, []string{"values"}}},
+		{"my number check digit", Column{Name: "ID", samples: []string{"123456789018", "987654321093"}},
+			&Classification{"MyNumber", 0.9, []string{"values"}}},
+		{"invalid check digit", Column{Name: "ID", samples: []string{"123456789012", "987654321093"}}, nil},
+		{"credit card", Column{Name: "CARD_NO", samples: []string{"4111-1111-1111-1111"}},
+			&Classification{"CreditCard", 0.99, []string{"name", "values"}}},
+		{"not personal", Column{Name: "DEPTNAME", Alias: "部門名"}, nil},
+		{"bare name", Column{Name: "NAME", Alias: "商品名"}, nil},
+		{"bare name with kanji", Column{Name: "NAME", Alias: "氏名"},
+			&Classification{"Name", 0.7, []string{"alias"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(tt.col); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Classify() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadClassificationRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`[
+		{"category": "Email", "names": ["CONTACT"]},
+		{"category": "EmployeeID", "textPattern": "社員番号"}
+	]`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadClassificationRules(path)
+	if err != nil {
+		t.Fatalf("LoadClassificationRules() error :%s", err)
+	}
+	c, err := NewClassifier(rules)
+	if err != nil {
+		t.Fatalf("NewClassifier() error :%s", err)
+	}
+	if got := c.Classify(Column{Name: "EMAIL"}); got != nil {
+		t.Errorf("Classify(EMAIL) = %#v, want nil", got)
+	}
+	if got := c.Classify(Column{Name: "CONTACT"}); got == nil || got.Category != "Email" {
+		t.Errorf("Classify(CONTACT) = %#v, want Email", got)
+	}
+	if got := c.Classify(Column{Name: "EMPNO", Description: "社員番号"}); got == nil || got.Category != "EmployeeID" {
+		t.Errorf("Classify(EMPNO) = %#v, want EmployeeID", got)
+	}
+
+	_, err = NewClassifier([]ClassificationRule{{Category: "X", ValuePattern: "("}})
+	if err == nil {
+		t.Error("NewClassifier() with invalid pattern error is nil")
+	}
+}
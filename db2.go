@@ -9,6 +9,7 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,26 +25,84 @@ type Db2DSN struct {
 	Port     int    `json:"port"`
 	UserID   string `json:"userid"`
 	Password string `json:"password"`
+	// Security は、SSL 接続の場合に "SSL" を指定します。
+	// SSL の証明書やキーストアが指定されている場合は省略できます。
+	Security string `json:"security"`
+	// SSLServerCertificate は、サーバー証明書(ARM 形式)のパスです。
+	SSLServerCertificate string `json:"sslServerCertificate"`
+	// SSLClientKeystoreDB は、クライアントのキーストア(.kdb)のパスです。
+	SSLClientKeystoreDB string `json:"sslClientKeystoredb"`
+	// SSLClientKeystash は、キーストアのパスワードを格納した stash ファイルのパスです。
+	SSLClientKeystash string `json:"sslClientKeystash"`
+	// SSLClientHostnameValidation は、サーバーのホスト名検証(Basic, Off)です。
+	SSLClientHostnameValidation string `json:"sslClientHostnameValidation"`
+	// Options は、そのまま DSN に追加する CLI/ODBC キーワードです。
+	Options map[string]string `json:"options"`
 }
 
 // DSN は、sql.DB.Open() に渡す文字列を返します。
 func (n *Db2DSN) DSN() string {
-	return fmt.Sprintf("HOSTNAME=%s;DATABASE=%s;PORT=%d;UID=%s;PWD=%s",
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("HOSTNAME=%s;DATABASE=%s;PORT=%d;UID=%s;PWD=%s",
 		n.Hostname,
 		n.Database,
 		n.Port,
-		n.UserID,
-		n.Password,
-	)
+		dsnValue(n.UserID),
+		dsnValue(n.Password),
+	))
+	security := n.Security
+	if security == "" && (n.SSLServerCertificate != "" || n.SSLClientKeystoreDB != "") {
+		security = "SSL"
+	}
+	for _, kv := range [][2]string{
+		{"Security", security},
+		{"SSLServerCertificate", n.SSLServerCertificate},
+		{"SSLClientKeystoredb", n.SSLClientKeystoreDB},
+		{"SSLClientKeystash", n.SSLClientKeystash},
+		{"SSLClientHostnameValidation", n.SSLClientHostnameValidation},
+	} {
+		if kv[1] != "" {
+			buf.WriteString(fmt.Sprintf(";%s=%s", kv[0], dsnValue(kv[1])))
+		}
+	}
+	keys := make([]string, 0, len(n.Options))
+	for k := range n.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf(";%s=%s", k, dsnValue(n.Options[k])))
+	}
+	return buf.String()
+}
+
+// dsnValue は、; などを含む値を {} で囲みます。値の中の } は }} にします。
+func dsnValue(v string) string {
+	if strings.ContainsAny(v, ";{}") || strings.TrimSpace(v) != v {
+		return "{" + strings.ReplaceAll(v, "}", "}}") + "}"
+	}
+	return v
+}
+
+// String は、パスワードを伏せた DSN を返します。fmt.Stringer の実装です。
+func (n *Db2DSN) String() string {
+	return RedactDSN(n.DSN())
+}
+
+// GoString は、パスワードを伏せた DSN を返します。fmt.GoStringer の実装です。
+func (n *Db2DSN) GoString() string {
+	return fmt.Sprintf("&Db2DSN{%s}", n.String())
 }
 
 // Db2Extractor は、PostgreSQL から Metadata を抽出します。
 type Db2Extractor struct {
-	pool   *sql.DB
-	config *Config
+	pool     *sql.DB
+	config   *Config
+	profiler *profiler
 }
 
 // Run は、メータデータの抽出を実行します。MetadataExtractor の実装です。
+// 対象スキーマごとのパイプラインを Config.Parallelism 個まで並行して実行します。
 func (e *Db2Extractor) Run(ctx context.Context,
 	dsn DataSourceName, out io.Writer) error {
 
@@ -51,15 +110,58 @@ func (e *Db2Extractor) Run(ctx context.Context,
 	defer cancel()
 
 	var err error
-	e.pool, err = sql.Open(Db2Driver, dsn.DSN())
+	e.pool, err = sql.Open(sqlDriver, dsn.DSN())
 	if err != nil {
 		return err
 	}
 	defer e.pool.Close()
 
-	tableCh := e.extractTables(myCtx)
-	columnCh := e.extractColumns(myCtx, tableCh)
-	return writeCSV(myCtx, columnCh, out)
+	e.profiler = newProfiler(e.pool, e.config)
+	e.countTables(myCtx)
+	output := extractSchemas(myCtx, e.config, func(ctx context.Context, config *Config) <-chan MetadataInProcess {
+		schema := *e
+		schema.config = config
+		return schema.extractSchema(ctx)
+	})
+	return writeMetadata(myCtx, output, out, e.config)
+}
+
+// countTables は、対象スキーマごとに extractTables と同じ種類のテーブルを数え、
+// 進み具合の分母に加えます。
+func (e *Db2Extractor) countTables(ctx context.Context) {
+	addProgressTotal(ctx, e.pool, e.config, fmt.Sprintf(`
+		SELECT TABSCHEMA, COUNT(*) AS TABLES
+		FROM SYSCAT.TABLES
+		WHERE TYPE in %s
+		  AND TABSCHEMA in %s
+		GROUP BY TABSCHEMA`,
+		e.config.ObjectTypeInClause(db2ObjectTypes),
+		e.config.TargetSchemaInClause(),
+	))
+}
+
+// extractSchema は、e.config の対象スキーマのメタデータを抽出するパイプラインを組み立てます。
+func (e *Db2Extractor) extractSchema(ctx context.Context) <-chan MetadataInProcess {
+	tableCh := e.extractTables(ctx)
+	columnCh := e.extractColumns(ctx, tableCh)
+	keyCh := e.extractKeys(ctx, columnCh)
+	indexCh := e.extractIndexes(ctx, keyCh)
+	viewCh := e.extractViews(ctx, indexCh)
+	statisticsCh := e.extractStatistics(ctx, viewCh)
+	nicknameCh := e.extractNicknames(ctx, statisticsCh)
+	profileCh := e.extractProfiles(ctx, nicknameCh)
+	aliasCh := e.extractAliases(ctx, profileCh)
+	sequenceCh := e.extractSequences(ctx, aliasCh)
+	routineCh := e.extractRoutines(ctx, sequenceCh)
+	return classifyMetadata(ctx, routineCh, e.config)
+}
+
+// db2ObjectTypes は、Config.ObjectTypes の種類ごとの SYSCAT.TABLES の TYPE の値です。
+// 別名(TYPE 'A')はカラムがないため、extractAliases で抽出します。
+var db2ObjectTypes = map[string][]string{
+	"Table":    {"S", "T", "U"},
+	"View":     {"V", "W"},
+	"Nickname": {"N"},
 }
 
 // extractTables は、テーブル情報を抽出します。
@@ -78,35 +180,37 @@ func (e *Db2Extractor) extractTables(ctx context.Context,
 			  AND TABNAME='TABLES'
 			ORDER BY COLNO`)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
+			send(ctx, output, MetadataInProcess{Err: err})
 			return
 		}
 
 		query := NewQuery(cols, fmt.Sprintf(
 			`FROM SYSCAT.TABLES
-			WHERE TYPE in ('S', 'T', 'U', 'V', 'W')
+			WHERE TYPE in %s
 			  AND TABSCHEMA in %s
 			ORDER BY TABSCHEMA, TABNAME`,
+			e.config.ObjectTypeInClause(db2ObjectTypes),
 			e.config.TargetSchemaInClause(),
 		))
 
-		rows, err := query.Exec(ctx, e.pool)
+		next, closeRows, err := query.Open(ctx, e.pool)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
+			send(ctx, output, MetadataInProcess{Err: err})
 			return
 		}
-		defer rows.Close()
+		defer closeRows()
 
-		for rows.Next() {
-			m, err := query.Scan(rows)
+		for {
+			m, err := next()
+			if err == io.EOF {
+				return
+			}
 			if err != nil {
-				output <- MetadataInProcess{Err: err}
+				send(ctx, output, MetadataInProcess{Err: err})
 				return
 			}
-			select {
-			case <-ctx.Done():
+			if !send(ctx, output, MetadataInProcess{Data: *e.toMetadata(m)}) {
 				return
-			case output <- MetadataInProcess{Data: *e.toMetadata(m)}:
 			}
 		}
 	}()
@@ -143,10 +247,7 @@ func (e *Db2Extractor) toMetadata(m map[string]string) *Metadata {
 func (e *Db2Extractor) extractColumns(ctx context.Context,
 	input <-chan MetadataInProcess) <-chan MetadataInProcess {
 
-	output := make(chan MetadataInProcess)
-	go func() {
-		defer close(output)
-
+	return joinColumns(ctx, input, "columns", binaryOrder, func() (func() (*Column, string, error), func() error, error) {
 		cols, err := ColumnList(ctx, e.pool, `
 			SELECT COLNAME 
 			FROM SYSCAT.COLUMNS 
@@ -154,74 +255,33 @@ func (e *Db2Extractor) extractColumns(ctx context.Context,
 			  AND TABNAME='COLUMNS'
 			ORDER BY COLNO`)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
-			return
+			return nil, nil, err
 		}
 
+		// extractTables と同じ種類のテーブルのカラムに絞ります
 		query := NewQuery(cols, fmt.Sprintf(
 			`FROM SYSCAT.COLUMNS
 		    WHERE TABSCHEMA in %s
+			  AND (TABSCHEMA, TABNAME) in (
+			    SELECT TABSCHEMA, TABNAME FROM SYSCAT.TABLES WHERE TYPE in %s)
 			ORDER BY TABSCHEMA, TABNAME, COLNO`,
 			e.config.TargetSchemaInClause(),
+			e.config.ObjectTypeInClause(db2ObjectTypes),
 		))
 
-		rows, err := query.Exec(ctx, e.pool)
+		next, closeRows, err := query.Open(ctx, e.pool)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
-			return
+			return nil, nil, err
 		}
-		defer rows.Close()
-
-		var meta *Metadata
-		var col *Column
-		var formalName string
-		for rows.Next() {
-			if meta == nil {
-				select {
-				case <-ctx.Done():
-					return
-				case mip := <-input:
-					if mip.Err != nil {
-						output <- mip
-						return
-					}
-					meta = &mip.Data
-					if col != nil {
-						if meta.FormalName != formalName {
-							err = fmt.Errorf("meta.FormalName(%s) != formalName(%s)",
-								meta.FormalName, formalName)
-							output <- MetadataInProcess{Err: err}
-						}
-						meta.Columns = append(meta.Columns, *col)
-					}
-				}
-			}
-			m, err := query.Scan(rows)
+		return func() (*Column, string, error) {
+			m, err := next()
 			if err != nil {
-				output <- MetadataInProcess{Err: err}
-				return
+				return nil, "", err
 			}
-			col, formalName = e.toColumn(m)
-			if meta.FormalName == formalName {
-				meta.Columns = append(meta.Columns, *col)
-			} else {
-				select {
-				case <-ctx.Done():
-					return
-				case output <- MetadataInProcess{Data: *meta}:
-					meta = nil
-				}
-			}
-		}
-		if meta != nil {
-			select {
-			case <-ctx.Done():
-				return
-			case output <- MetadataInProcess{Data: *meta}:
-			}
-		}
-	}()
-	return output
+			col, formalName := e.toColumn(m)
+			return col, formalName, nil
+		}, closeRows, nil
+	})
 }
 
 // toColumn は、information_schema.columns の行の map から Column と
@@ -252,6 +312,51 @@ func (e *Db2Extractor) toColumn(m map[string]string) (*Column, string) {
 		}
 	}
 
+	if v, ok := m["LENGTH"]; ok {
+		length, err := strconv.Atoi(v)
+		if err == nil {
+			col.SetSize(length, catalogScale(m["SCALE"]))
+		}
+	}
+	if v, ok := m["CODEPAGE"]; ok {
+		i, err := strconv.Atoi(v)
+		if err == nil {
+			// 文字列のコードページが 0 のものは FOR BIT DATA です
+			col.CCSID = i
+			col.ForBitData = i == 0 && typeKind(col.Type) == typeKindCharacter
+		}
+	}
+
+	// GENERATED は識別カラム、式による生成カラム、行変更タイムスタンプで使われます
+	if v, ok := m["IDENTITY"]; ok && v == "Y" {
+		col.Identity = &Identity{Generation: generation(m["GENERATED"])}
+	} else if g := generation(m["GENERATED"]); g != "" {
+		col.Generated = &Generated{
+			Generation: g,
+			Expression: strings.TrimPrefix(strings.TrimSpace(m["TEXT"]), "AS "),
+		}
+		if m["ROWCHANGETIMESTAMP"] == "Y" {
+			col.Generated.Expression = "ROW CHANGE TIMESTAMP"
+		}
+	} else if v, ok := m["DEFAULT"]; ok {
+		col.Default = strings.TrimSpace(v)
+	}
+
+	// 統計情報を収集していないカラムは COLCARD が -1 です
+	if i, err := strconv.ParseInt(m["COLCARD"], 10, 64); e.config.Statistics && err == nil && i >= 0 {
+		s := newColumnStatistics()
+		s.Distinct = i
+		if i, err := strconv.ParseInt(m["NUMNULLS"], 10, 64); err == nil {
+			s.Nulls = i
+		}
+		s.High = strings.TrimSpace(m["HIGH2KEY"])
+		s.Low = strings.TrimSpace(m["LOW2KEY"])
+		if i, err := strconv.Atoi(m["AVGCOLLEN"]); err == nil && i > 0 {
+			s.AvgLength = i
+		}
+		col.Statistics = s
+	}
+
 	var formalName string
 	if v, ok := m["TABSCHEMA"]; ok {
 		formalName = strings.TrimSpace(v)
@@ -260,13 +365,6 @@ func (e *Db2Extractor) toColumn(m map[string]string) (*Column, string) {
 	if v, ok := m["TABNAME"]; ok {
 		formalName += v
 	}
-	if v, ok := m["KEYSEQ"]; ok {
-		i, err := strconv.Atoi(v)
-		if err == nil {
-			col.KeyType.Constraint = 1
-			col.KeyType.Order = i
-		}
-	}
 	if v, ok := m["REMARKS"]; ok {
 		for _, str := range e.config.Remarks {
 			switch str {
@@ -280,30 +378,591 @@ func (e *Db2Extractor) toColumn(m map[string]string) (*Column, string) {
 	return col, formalName
 }
 
+// extractKeys は、主キー、一意キー、外部キーを抽出して Metadata に設定します。
+// KEYSEQ は主キーしか表さないため、制約のカタログから取得します。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscattabconst
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatkeycoluse
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatreferences
+func (e *Db2Extractor) extractKeys(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		constraints := make(map[string][]Constraint)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT C.TABSCHEMA, C.TABNAME, C.CONSTNAME, C.TYPE,
+			       K.COLNAME, K.COLSEQ,
+			       R.REFTABSCHEMA, R.REFTABNAME, RK.COLNAME AS REFCOLNAME
+			FROM SYSCAT.TABCONST C
+			JOIN SYSCAT.KEYCOLUSE K
+			  ON K.CONSTNAME = C.CONSTNAME
+			 AND K.TABSCHEMA = C.TABSCHEMA
+			 AND K.TABNAME = C.TABNAME
+			LEFT JOIN SYSCAT.REFERENCES R
+			  ON R.CONSTNAME = C.CONSTNAME
+			 AND R.TABSCHEMA = C.TABSCHEMA
+			 AND R.TABNAME = C.TABNAME
+			LEFT JOIN SYSCAT.KEYCOLUSE RK
+			  ON RK.CONSTNAME = R.REFKEYNAME
+			 AND RK.TABSCHEMA = R.REFTABSCHEMA
+			 AND RK.TABNAME = R.REFTABNAME
+			 AND RK.COLSEQ = K.COLSEQ
+			WHERE C.TYPE in ('P', 'U', 'F')
+			  AND C.TABSCHEMA in %s
+			ORDER BY C.TABSCHEMA, C.TABNAME, C.CONSTNAME, K.COLSEQ`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			var typ int
+			switch m["TYPE"] {
+			case "P":
+				typ = 1
+			case "U":
+				typ = 2
+			case "F":
+				typ = 3
+			}
+			var refTable string
+			if v, ok := m["REFTABNAME"]; ok {
+				refTable = strings.TrimSpace(m["REFTABSCHEMA"]) + "." + v
+			}
+			addConstraint(constraints,
+				strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"],
+				m["CONSTNAME"], typ, m["COLNAME"], refTable, m["REFCOLNAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.SetConstraints(constraints[meta.FormalName])
+		}, nil
+	})
+}
+
+// extractIndexes は、索引を抽出して Metadata に設定します。
+// COLORDER が I のカラムは INCLUDE カラムです。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatindexes
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatindexcoluse
+func (e *Db2Extractor) extractIndexes(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		indexes := make(map[string][]Index)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT I.TABSCHEMA, I.TABNAME, I.INDSCHEMA, I.INDNAME,
+			       I.UNIQUERULE, I.INDEXTYPE,
+			       C.COLNAME, C.COLSEQ, C.COLORDER
+			FROM SYSCAT.INDEXES I
+			JOIN SYSCAT.INDEXCOLUSE C
+			  ON C.INDSCHEMA = I.INDSCHEMA
+			 AND C.INDNAME = I.INDNAME
+			WHERE I.TABSCHEMA in %s
+			ORDER BY I.TABSCHEMA, I.TABNAME, I.INDSCHEMA, I.INDNAME, C.COLSEQ`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			addIndexColumn(indexes,
+				strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"],
+				Index{
+					Schema:    strings.TrimSpace(m["INDSCHEMA"]),
+					Name:      m["INDNAME"],
+					Unique:    m["UNIQUERULE"] != "D",
+					Clustered: strings.TrimSpace(m["INDEXTYPE"]) == "CLUS",
+				},
+				m["COLNAME"], indexOrder(m["COLORDER"]), m["COLORDER"] == "I")
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Indexes = indexes[meta.FormalName]
+		}, nil
+	})
+}
+
+// extractViews は、ビューの定義と参照するテーブルを抽出して Metadata に設定します。
+// ビューのカラムには、定義を解析した元のカラムを設定します。参照するテーブルの
+// カラム名は、抽出の対象外のスキーマのテーブルも含めて COLUMNS から読み込みます。
+// TABDEP には関数や型への依存もあるため、テーブルの種類に絞ります。
+// 修飾されていないテーブル名は、VIEWS の QUALIFIER のスキーマで解決します。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatviews
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscattabdep
+func (e *Db2Extractor) extractViews(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		definitions := make(map[string]string)
+		qualifiers := make(map[string]string)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT VIEWSCHEMA, VIEWNAME, SEQNO, QUALIFIER, TEXT
+			FROM SYSCAT.VIEWS
+			WHERE VIEWSCHEMA in %s
+			ORDER BY VIEWSCHEMA, VIEWNAME, SEQNO`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			formalName := strings.TrimSpace(m["VIEWSCHEMA"]) + "." + m["VIEWNAME"]
+			definitions[formalName] += m["TEXT"]
+			qualifiers[formalName] = strings.TrimSpace(m["QUALIFIER"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		baseTables := make(map[string][]string)
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABSCHEMA, TABNAME, BSCHEMA, BNAME
+			FROM SYSCAT.TABDEP
+			WHERE DTYPE = 'V'
+			  AND BTYPE in ('A', 'G', 'N', 'S', 'T', 'U', 'V', 'W')
+			  AND TABSCHEMA in %s
+			ORDER BY TABSCHEMA, TABNAME, BSCHEMA, BNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			formalName := strings.TrimSpace(m["TABSCHEMA"]) + "." + m["TABNAME"]
+			baseTables[formalName] = append(baseTables[formalName],
+				strings.TrimSpace(m["BSCHEMA"])+"."+m["BNAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		lineage := newLineageResolver()
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT C.TABSCHEMA, C.TABNAME, C.COLNAME
+			FROM SYSCAT.COLUMNS C
+			JOIN (SELECT DISTINCT BSCHEMA, BNAME
+			      FROM SYSCAT.TABDEP
+			      WHERE DTYPE = 'V'
+			        AND BTYPE in ('A', 'G', 'N', 'S', 'T', 'U', 'V', 'W')
+			        AND TABSCHEMA in %s) D
+			  ON D.BSCHEMA = C.TABSCHEMA AND D.BNAME = C.TABNAME
+			ORDER BY C.TABSCHEMA, C.TABNAME, C.COLNO`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			lineage.addColumn(strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"], m["COLNAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.SetView(definitions[meta.FormalName], qualifiers[meta.FormalName], baseTables[meta.FormalName])
+			lineage.Apply(meta)
+		}, nil
+	})
+}
+
+// extractStatistics は、Config.Statistics が真の場合に、テーブルとニックネームの
+// 統計情報、テーブルスペース、圧縮、分散キーを抽出して Metadata に設定します。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscattables
+func (e *Db2Extractor) extractStatistics(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		if !e.config.Statistics {
+			return func(meta *Metadata) {}, nil
+		}
+		statistics := make(map[string]*TableStatistics)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABSCHEMA, TABNAME, CARD, NPAGES, STATS_TIME, TBSPACE, COMPRESSION, ROWCOMPMODE
+			FROM SYSCAT.TABLES
+			WHERE TYPE in ('N', 'S', 'T', 'U')
+			  AND TABSCHEMA in %s
+			ORDER BY TABSCHEMA, TABNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			s := newTableStatistics()
+			if i, err := strconv.ParseInt(m["CARD"], 10, 64); err == nil {
+				s.RowCount = i
+			}
+			if i, err := strconv.ParseInt(m["NPAGES"], 10, 64); err == nil {
+				s.Pages = i
+			}
+			s.StatsTime = statsTime(m["STATS_TIME"])
+			s.Tablespace = strings.TrimSpace(m["TBSPACE"])
+			s.Compression = e.compression(m["COMPRESSION"], m["ROWCOMPMODE"])
+			statistics[strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"]] = s
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABSCHEMA, TABNAME, COLNAME
+			FROM SYSCAT.COLUMNS
+			WHERE PARTKEYSEQ > 0
+			  AND TABSCHEMA in %s
+			ORDER BY TABSCHEMA, TABNAME, PARTKEYSEQ`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			if s, ok := statistics[strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"]]; ok {
+				s.PartitionKeys = append(s.PartitionKeys, m["COLNAME"])
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Statistics = statistics[meta.FormalName]
+		}, nil
+	})
+}
+
+// extractProfiles は、Config.Profile が真の場合に、テーブルのデータを標本にして
+// カラムのプロファイルを作ります。行数が上限を超えるテーブルは TABLESAMPLE で
+// ページ単位の標本を取ります。ビューとニックネームは対象外です。標本を取れなかった
+// テーブルは、プロファイルを設定せずに Warning とともに流します。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=clause-table-reference
+func (e *Db2Extractor) extractProfiles(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return tryEnrichMetadata(ctx, input, "profiles", func() (func(meta *Metadata) error, error) {
+		if !e.config.Profile {
+			return func(meta *Metadata) error { return nil }, nil
+		}
+		cards := make(map[string]int64)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABSCHEMA, TABNAME, CARD
+			FROM SYSCAT.TABLES
+			WHERE TYPE in ('S', 'T', 'U')
+			  AND TABSCHEMA in %s`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			card, err := strconv.ParseInt(m["CARD"], 10, 64)
+			if err != nil {
+				card = -1
+			}
+			cards[strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"]] = card
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := e.profiler
+		return func(meta *Metadata) error {
+			card, ok := cards[meta.FormalName]
+			if !ok {
+				return nil
+			}
+			sample := ""
+			if percent := p.samplePercent(card); percent > 0 {
+				sample = fmt.Sprintf("TABLESAMPLE SYSTEM (%s)", strconv.FormatFloat(percent, 'f', -1, 64))
+			}
+			return p.profile(ctx, meta, sample, card)
+		}, nil
+	})
+}
+
+// compression は、SYSCAT.TABLES の COMPRESSION と ROWCOMPMODE から圧縮の種類を返します。
+func (e *Db2Extractor) compression(code string, mode string) string {
+	list := []string{}
+	if code == "R" || code == "B" {
+		switch mode {
+		case "A":
+			list = append(list, "ROW ADAPTIVE")
+		case "S":
+			list = append(list, "ROW STATIC")
+		default:
+			list = append(list, "ROW")
+		}
+	}
+	if code == "V" || code == "B" {
+		list = append(list, "VALUE")
+	}
+	return strings.Join(list, ", ")
+}
+
+// extractNicknames は、ニックネームが参照するリモートのオブジェクトを Metadata に設定します。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatnicknames
+func (e *Db2Extractor) extractNicknames(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		if !e.config.IncludesObjectType("Nickname") {
+			return func(meta *Metadata) {}, nil
+		}
+		remotes := make(map[string]*RemoteObject)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABSCHEMA, TABNAME, SERVERNAME, REMOTE_SCHEMA, REMOTE_TABLE, REMOTE_TYPE
+			FROM SYSCAT.NICKNAMES
+			WHERE TABSCHEMA in %s
+			ORDER BY TABSCHEMA, TABNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			remotes[strings.TrimSpace(m["TABSCHEMA"])+"."+m["TABNAME"]] = &RemoteObject{
+				Server: m["SERVERNAME"],
+				Schema: m["REMOTE_SCHEMA"],
+				Name:   m["REMOTE_TABLE"],
+				Type:   strings.TrimSpace(m["REMOTE_TYPE"]),
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Remote = remotes[meta.FormalName]
+		}, nil
+	})
+}
+
+// extractAliases は、別名を抽出し、テーブルの後に Metadata として流します。
+// 別名のカラムは、参照先のテーブル、ビューのカラムを COLUMNS から読み込むため、
+// 参照先のスキーマが抽出の対象外でも設定します。参照先の別名が抽出の対象外の
+// スキーマにあり、たどれない場合、カラムは空です。
+func (e *Db2Extractor) extractAliases(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return appendMetadata(ctx, input, func() ([]Metadata, error) {
+		list := []Metadata{}
+		if !e.config.IncludesObjectType("Alias") {
+			return list, nil
+		}
+		bases := make(map[string]string)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABSCHEMA, TABNAME, BASE_TABSCHEMA, BASE_TABNAME, REMARKS
+			FROM SYSCAT.TABLES
+			WHERE TYPE = 'A'
+			  AND TABSCHEMA in %s
+			ORDER BY TABSCHEMA, TABNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			meta := e.toMetadata(m)
+			meta.BaseObject = strings.TrimSpace(m["BASE_TABSCHEMA"]) + "." + m["BASE_TABNAME"]
+			bases[meta.FormalName] = meta.BaseObject
+			list = append(list, *meta)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return list, nil
+		}
+
+		cols, err := ColumnList(ctx, e.pool, `
+			SELECT COLNAME 
+			FROM SYSCAT.COLUMNS 
+			WHERE TABSCHEMA='SYSCAT'
+			  AND TABNAME='COLUMNS'
+			ORDER BY COLNO`)
+		if err != nil {
+			return nil, err
+		}
+		columns := make(map[string][]Column)
+		next, closeRows, err := NewQuery(cols, fmt.Sprintf(
+			`FROM SYSCAT.COLUMNS
+			WHERE (TABSCHEMA, TABNAME) in (
+			    SELECT BASE_TABSCHEMA, BASE_TABNAME FROM SYSCAT.TABLES
+			    WHERE TYPE = 'A' AND TABSCHEMA in %s)
+			ORDER BY TABSCHEMA, TABNAME, COLNO`,
+			e.config.TargetSchemaInClause(),
+		)).Open(ctx, e.pool)
+		if err != nil {
+			return nil, err
+		}
+		defer closeRows()
+		for {
+			m, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			col, formalName := e.toColumn(m)
+			columns[formalName] = append(columns[formalName], *col)
+		}
+		for i := range list {
+			list[i].Columns = columns[resolveAlias(bases, list[i].BaseObject)]
+		}
+		return list, nil
+	})
+}
+
+// resolveAlias は、別名の別名をたどり、別名ではない参照先の正式名を返します。
+func resolveAlias(bases map[string]string, formalName string) string {
+	// 別名の循環は作成できませんが、念のため別名の数で打ち切ります
+	for range bases {
+		base, ok := bases[formalName]
+		if !ok {
+			break
+		}
+		formalName = base
+	}
+	return formalName
+}
+
+// extractSequences は、シーケンスを抽出し、MetaType が Model の Metadata として流します。
+// 識別カラムのシーケンス(SEQTYPE 'I')は除きます。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatsequences
+func (e *Db2Extractor) extractSequences(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return appendMetadata(ctx, input, func() ([]Metadata, error) {
+		list := []Metadata{}
+		if !e.config.IncludesObjectType("Sequence") {
+			return list, nil
+		}
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT S.SEQSCHEMA, S.SEQNAME, T.TYPESCHEMA, T.TYPENAME, S.START, S.INCREMENT,
+			       S.MINVALUE, S.MAXVALUE, S.CYCLE, S.CACHE, S.REMARKS
+			FROM SYSCAT.SEQUENCES S
+			JOIN SYSCAT.DATATYPES T ON T.TYPEID = S.DATATYPEID
+			WHERE S.SEQTYPE = 'S'
+			  AND S.SEQSCHEMA in %s
+			ORDER BY S.SEQSCHEMA, S.SEQNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			meta := Metadata{
+				Name:       m["SEQNAME"],
+				FormalName: strings.TrimSpace(m["SEQSCHEMA"]) + "." + m["SEQNAME"],
+				MetaType:   2, // core.ModelData
+				Lang:       e.config.Lang,
+				Sequence: &Sequence{
+					DataType:  strings.TrimSpace(m["TYPESCHEMA"]) + "." + m["TYPENAME"],
+					Start:     m["START"],
+					Increment: m["INCREMENT"],
+					MinValue:  m["MINVALUE"],
+					MaxValue:  m["MAXVALUE"],
+					Cycle:     m["CYCLE"] == "Y",
+				},
+			}
+			meta.Sequence.Cache, _ = strconv.Atoi(m["CACHE"])
+			for _, str := range e.config.Remarks {
+				switch str {
+				case "Alias":
+					meta.Alias = m["REMARKS"]
+				case "Description":
+					meta.Description = m["REMARKS"]
+				}
+			}
+			list = append(list, meta)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return list, nil
+	})
+}
+
+// extractRoutines は、ストアドプロシージャーとユーザー定義関数を抽出し、
+// テーブルの後に MetaType が Model の Metadata として流します。
+// システムが生成した関数(ORIGIN 'S')とメソッドは除きます。
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatroutines
+// https://www.ibm.com/docs/ja/db2/11.5?topic=views-syscatroutineparms
+func (e *Db2Extractor) extractRoutines(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return appendMetadata(ctx, input, func() ([]Metadata, error) {
+		if !e.config.IncludesObjectType("Routine") {
+			return nil, nil
+		}
+		routines := newRoutineList()
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT ROUTINESCHEMA, ROUTINENAME, SPECIFICNAME, ROUTINETYPE, LANGUAGE, REMARKS, TEXT
+			FROM SYSCAT.ROUTINES
+			WHERE ROUTINETYPE in ('F', 'P')
+			  AND ORIGIN <> 'S'
+			  AND ROUTINESCHEMA in %s
+			ORDER BY ROUTINESCHEMA, ROUTINENAME, SPECIFICNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			schema := strings.TrimSpace(m["ROUTINESCHEMA"])
+			meta := Metadata{
+				Name:       m["ROUTINENAME"],
+				FormalName: schema + "." + m["ROUTINENAME"],
+				MetaType:   2, // core.ModelData
+				Lang:       e.config.Lang,
+				Routine: &Routine{
+					Type:         routineType(m["ROUTINETYPE"]),
+					SpecificName: m["SPECIFICNAME"],
+					Language:     strings.TrimSpace(m["LANGUAGE"]),
+					Definition:   m["TEXT"],
+				},
+			}
+			for _, str := range e.config.Remarks {
+				switch str {
+				case "Alias":
+					meta.Alias = m["REMARKS"]
+				case "Description":
+					meta.Description = m["REMARKS"]
+				}
+			}
+			routines.add(schema, meta)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// 表関数の結果のカラム(ROWTYPE 'C')は、パラメーターの後に並べます
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT ROUTINESCHEMA, SPECIFICNAME, PARMNAME, ROWTYPE, ORDINAL,
+			       TYPESCHEMA, TYPENAME, LENGTH, SCALE, CODEPAGE, REMARKS
+			FROM SYSCAT.ROUTINEPARMS
+			WHERE ROWTYPE in ('B', 'C', 'O', 'P')
+			  AND ROUTINESCHEMA in %s
+			ORDER BY ROUTINESCHEMA, SPECIFICNAME, CASE ROWTYPE WHEN 'C' THEN 1 ELSE 0 END, ORDINAL`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			routines.addParameter(strings.TrimSpace(m["ROUTINESCHEMA"]), m["SPECIFICNAME"],
+				e.toParameter(m))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return routines.Metadata(), nil
+	})
+}
+
+// toParameter は、SYSCAT.ROUTINEPARMS の行の map から Column を作ります。
+// スカラー関数の戻り値は名前がないため、RETURN とします。
+func (e *Db2Extractor) toParameter(m map[string]string) Column {
+	col := Column{
+		Name:      m["PARMNAME"],
+		Type:      strings.TrimSpace(m["TYPESCHEMA"]) + "." + m["TYPENAME"],
+		Parameter: parameterMode(m["ROWTYPE"]),
+	}
+	if col.Name == "" {
+		col.Name = col.Parameter
+	}
+	length, err := strconv.Atoi(m["LENGTH"])
+	if err == nil {
+		col.SetSize(length, catalogScale(m["SCALE"]))
+	}
+	codepage, err := strconv.Atoi(m["CODEPAGE"])
+	if err == nil {
+		col.CCSID = codepage
+		col.ForBitData = codepage == 0 && typeKind(col.Type) == typeKindCharacter
+	}
+	for _, str := range e.config.Remarks {
+		switch str {
+		case "Alias":
+			col.Alias = m["REMARKS"]
+		case "Description":
+			col.Description = m["REMARKS"]
+		}
+	}
+	return col
+}
+
 // FindSchema は、スキーマの一覧を取得する。
 func (e *Db2Extractor) FindSchema(ctx context.Context, dsn DataSourceName) ([]string, error) {
-	db, err := sql.Open(Db2Driver, dsn.DSN())
+	db, err := sql.Open(sqlDriver, dsn.DSN())
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	rows, err := db.QueryContext(ctx, `
+	return ColumnList(ctx, db, `
 	    SELECT TABSCHEMA
 		FROM SYSCAT.TABLES
 		GROUP BY TABSCHEMA`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	result := []string{}
-	for rows.Next() {
-		var column string
-		rows.Scan(&column)
-		result = append(result, column)
-	}
-	return result, nil
 }
 
 func (e *Db2Extractor) SetConfig(config *Config) {
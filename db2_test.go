@@ -5,8 +5,11 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"os"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -14,7 +17,7 @@ func TestDb2Run(t *testing.T) {
 	ctx := context.Background()
 	config := &Config{
 		Hostname:     "localhost",
-		Database:     "SAMPLE",
+		Database:     "LUW",
 		Port:         50000,
 		UserID:       "db2inst1",
 		Password:     "password",
@@ -24,25 +27,51 @@ func TestDb2Run(t *testing.T) {
 		SystemSchema: "SYSCAT",
 		TargetSchema: []string{"DB2INST1"},
 	}
-	output, err := os.Create(config.CSVFile)
-	if err != nil {
-		t.Fatalf("os.Open() error :%s", err)
-	}
-	defer output.Close()
+	output := &bytes.Buffer{}
 
 	extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
 	extractor.SetConfig(config)
-	err = extractor.Run(ctx, config.Db2DSN(), output)
+	err := extractor.Run(ctx, config.Db2DSN(), output)
 	if err != nil {
 		t.Fatalf("Run() error :%s", err)
 	}
+	assertGolden(t, "testdata/golden/db2.csv", output.Bytes())
+}
+
+func TestDb2RunFormats(t *testing.T) {
+	tests := []struct {
+		format      string
+		annotations []string
+		golden      string
+	}{
+		{"mashu", []string{"Keys", "Default", "Identity", "Generated", "Indexes", "View", "Lineage", "Routine"},
+			"testdata/golden/db2_annotated.csv"},
+		{"json", nil, "testdata/golden/db2.json"},
+		{"ddl", nil, "testdata/golden/db2.sql"},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		config := testConfig()
+		config.TargetSchema = []string{"DB2INST1"}
+		config.Format = tt.format
+		config.Annotations = tt.annotations
+		output := &bytes.Buffer{}
+
+		extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
+		extractor.SetConfig(config)
+		err := extractor.Run(ctx, config.Db2DSN(), output)
+		if err != nil {
+			t.Fatalf("Run() error :%s", err)
+		}
+		assertGolden(t, tt.golden, output.Bytes())
+	}
 }
 
 func TestDb2FindScehma(t *testing.T) {
 	ctx := context.Background()
 	config := &Config{
 		Hostname:     "localhost",
-		Database:     "SAMPLE",
+		Database:     "LUW",
 		Port:         50000,
 		UserID:       "db2inst1",
 		Password:     "password",
@@ -58,5 +87,363 @@ func TestDb2FindScehma(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Run() error :%s", err)
 	}
-	t.Logf("%#v", list)
+	want := []string{"DB2INST1", "NULLID", "SYSCAT", "SYSIBM", "SYSTOOLS"}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("FindSchema() = %#v, want %#v", list, want)
+	}
+}
+
+func TestDb2DSN(t *testing.T) {
+	tests := []struct {
+		dsn  Db2DSN
+		want string
+	}{
+		{
+			dsn: Db2DSN{Hostname: "localhost", Database: "SAMPLE", Port: 50000,
+				UserID: "db2inst1", Password: "password"},
+			want: "HOSTNAME=localhost;DATABASE=SAMPLE;PORT=50000;UID=db2inst1;PWD=password",
+		},
+		{
+			dsn: Db2DSN{Hostname: "db2.example.com", Database: "PROD", Port: 50001,
+				UserID: "db2inst1", Password: "pa;ss",
+				SSLServerCertificate: "/etc/db2/server.arm"},
+			want: "HOSTNAME=db2.example.com;DATABASE=PROD;PORT=50001;UID=db2inst1;PWD={pa;ss}" +
+				";Security=SSL;SSLServerCertificate=/etc/db2/server.arm",
+		},
+		{
+			dsn: Db2DSN{Hostname: "localhost", Database: "SAMPLE", Port: 50000,
+				UserID: "db2inst1", Password: "ab}c;x"},
+			want: "HOSTNAME=localhost;DATABASE=SAMPLE;PORT=50000;UID=db2inst1;PWD={ab}}c;x}",
+		},
+		{
+			dsn: Db2DSN{Hostname: "localhost", Database: "SAMPLE", Port: 50000,
+				UserID: "db2inst1", Password: "{pass"},
+			want: "HOSTNAME=localhost;DATABASE=SAMPLE;PORT=50000;UID=db2inst1;PWD={{pass}",
+		},
+		{
+			dsn: Db2DSN{
+				Hostname:                    "zos.example.com",
+				Database:                    "DSNLOC",
+				Port:                        448,
+				UserID:                      "ibmuser",
+				Password:                    "password",
+				Security:                    "SSL",
+				SSLClientKeystoreDB:         "/etc/db2/client.kdb",
+				SSLClientKeystash:           "/etc/db2/client.sth",
+				SSLClientHostnameValidation: "Basic",
+				Options:                     map[string]string{"ConnectTimeout": "30", "CurrentSchema": "DB2INST1"},
+			},
+			want: "HOSTNAME=zos.example.com;DATABASE=DSNLOC;PORT=448;UID=ibmuser;PWD=password" +
+				";Security=SSL;SSLClientKeystoredb=/etc/db2/client.kdb" +
+				";SSLClientKeystash=/etc/db2/client.sth;SSLClientHostnameValidation=Basic" +
+				";ConnectTimeout=30;CurrentSchema=DB2INST1",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.dsn.DSN(); got != tt.want {
+			t.Errorf("DSN() = %q, want %q", got, tt.want)
+		}
+		if strings.Contains(tt.dsn.String(), tt.dsn.Password) {
+			t.Errorf("String() = %q, password is not redacted", tt.dsn.String())
+		}
+	}
+}
+
+func TestDb2ObjectTypes(t *testing.T) {
+	config := testConfig()
+	config.TargetSchema = []string{"DB2INST1"}
+	config.ObjectTypes = []string{"Table", "View", "Alias", "Nickname", "Sequence"}
+	result := runExtractor(t, config)
+
+	nickname := result["DB2INST1.RSALES"]
+	remote := &RemoteObject{Server: "ORASRV", Schema: "SALES", Name: "ORDERS", Type: "T"}
+	if !reflect.DeepEqual(nickname.Remote, remote) {
+		t.Errorf("RSALES Remote = %#v, want %#v", nickname.Remote, remote)
+	}
+	if len(nickname.Columns) != 2 {
+		t.Errorf("RSALES Columns = %d, want 2", len(nickname.Columns))
+	}
+	ddl := `CREATE NICKNAME "DB2INST1"."RSALES" FOR "ORASRV"."SALES"."ORDERS";` + "\n" +
+		`COMMENT ON NICKNAME "DB2INST1"."RSALES" IS '受注(リモート)';` + "\n"
+	if got := nickname.ToDDLString(); !strings.HasPrefix(got, ddl) {
+		t.Errorf("RSALES ToDDLString() = %s, want prefix %s", got, ddl)
+	}
+
+	// 別名のカラムはカタログから読むため、キーは参照先のテーブルにだけ設定します
+	employee := append([]Column{}, result["DB2INST1.EMPLOYEE"].Columns...)
+	for i := range employee {
+		employee[i].KeyType = KeyType{}
+	}
+	for _, name := range []string{"DB2INST1.EMP", "DB2INST1.STAFF"} {
+		alias := result[name]
+		if alias.MetaType != 1 || !reflect.DeepEqual(alias.Columns, employee) {
+			t.Errorf("%s = %#v, want columns of EMPLOYEE", name, alias)
+		}
+	}
+	if got := result["DB2INST1.STAFF"].BaseObject; got != "DB2INST1.EMP" {
+		t.Errorf("STAFF BaseObject = %s, want DB2INST1.EMP", got)
+	}
+
+	sequence := result["DB2INST1.ORDER_SEQ"]
+	want := "AS INTEGER START WITH 1 INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 NO CYCLE CACHE 20"
+	if sequence.MetaType != 2 || sequence.Sequence == nil || sequence.Sequence.Clause() != want {
+		t.Errorf("ORDER_SEQ = %#v, want Sequence %s", sequence, want)
+	}
+	ddl = `CREATE SEQUENCE "DB2INST1"."ORDER_SEQ" ` + want + ";\n"
+	if got := sequence.ToDDLString(); !strings.HasPrefix(got, ddl) {
+		t.Errorf("ORDER_SEQ ToDDLString() = %s, want prefix %s", got, ddl)
+	}
+
+	if _, ok := result["DB2INST1.RAISE_SALARY"]; ok {
+		t.Errorf("routines are extracted without Routine in ObjectTypes")
+	}
+}
+
+func TestDb2ViewLineage(t *testing.T) {
+	// 参照先のテーブルが流れなくても、カタログのカラムで元のカラムを解決します
+	config := testConfig()
+	config.TargetSchema = []string{"DB2INST1"}
+	config.ObjectTypes = []string{"View"}
+	result := runExtractor(t, config)
+
+	if _, ok := result["DB2INST1.EMPLOYEE"]; ok {
+		t.Errorf("tables are extracted without Table in ObjectTypes")
+	}
+	want := map[string][]ColumnSource{
+		"EMPNO": {{Table: "DB2INST1.EMPLOYEE", Column: "EMPNO"}},
+		"NAME": {{Table: "DB2INST1.EMPLOYEE", Column: "FIRSTNME"},
+			{Table: "DB2INST1.EMPLOYEE", Column: "LASTNAME"}},
+		"DEPTNAME": {{Table: "DB2INST1.DEPARTMENT", Column: "DEPTNAME"}},
+	}
+	for name, lineage := range want {
+		if got := findColumn(t, result["DB2INST1.VEMP"], name).Lineage; !reflect.DeepEqual(got, lineage) {
+			t.Errorf("VEMP.%s Lineage = %v, want %v", name, got, lineage)
+		}
+	}
+}
+
+func TestDb2CrossSchema(t *testing.T) {
+	// APP のビューと別名は、別のパイプラインで抽出する DB2INST1 のテーブルを参照します
+	config := testConfig()
+	config.TargetSchema = []string{"APP", "DB2INST1"}
+	config.ObjectTypes = []string{"Table", "View", "Alias"}
+	config.Parallelism = 2
+	var warnings []Warning
+	config.WarningHandler = func(w Warning) { warnings = append(warnings, w) }
+	result := runExtractor(t, config)
+
+	// VEMPNAME は、既定のスキーマを DB2INST1 にして修飾せずに EMPLOYEE を参照します
+	if got := result["APP.VEMPNAME"].View.Qualifier; got != "DB2INST1" {
+		t.Errorf("VEMPNAME Qualifier = %s, want DB2INST1", got)
+	}
+	want := []ColumnSource{{Table: "DB2INST1.EMPLOYEE", Column: "LASTNAME"}}
+	if got := findColumn(t, result["APP.VEMPNAME"], "LASTNAME").Lineage; !reflect.DeepEqual(got, want) {
+		t.Errorf("VEMPNAME.LASTNAME Lineage = %v, want %v", got, want)
+	}
+	alias := result["APP.EMPLOYEE"]
+	if alias.BaseObject != "DB2INST1.EMPLOYEE" || len(alias.Columns) != len(result["DB2INST1.EMPLOYEE"].Columns) {
+		t.Errorf("APP.EMPLOYEE = %s with %d columns, want columns of DB2INST1.EMPLOYEE",
+			alias.BaseObject, len(alias.Columns))
+	}
+	if len(warnings) > 0 {
+		t.Errorf("Run() warnings = %v, want none", warnings)
+	}
+}
+
+func TestDb2Statistics(t *testing.T) {
+	config := testConfig()
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+	if got := result["DB2INST1.EMPLOYEE"].Statistics; got != nil {
+		t.Errorf("EMPLOYEE Statistics = %#v, want nil", got)
+	}
+
+	config.Statistics = true
+	config.ProfileValues = true
+	result = runExtractor(t, config)
+	want := &TableStatistics{RowCount: 42, Pages: 2, StatsTime: "2024-04-02 03:00:00.000000",
+		Tablespace: "USERSPACE1", Compression: "ROW ADAPTIVE", PartitionKeys: []string{"EMPNO"}}
+	if got := result["DB2INST1.EMPLOYEE"].Statistics; !reflect.DeepEqual(got, want) {
+		t.Errorf("EMPLOYEE Statistics = %#v, want %#v", got, want)
+	}
+	if got := result["DB2INST1.VEMP"].Statistics; got != nil {
+		t.Errorf("VEMP Statistics = %#v, want nil", got)
+	}
+
+	column := &ColumnStatistics{Distinct: 8, Nulls: 1, High: "'E11'", Low: "'B01'", AvgLength: 4}
+	if got := findColumn(t, result["DB2INST1.EMPLOYEE"], "WORKDEPT").Statistics; !reflect.DeepEqual(got, column) {
+		t.Errorf("EMPLOYEE.WORKDEPT Statistics = %#v, want %#v", got, column)
+	}
+	if got := findColumn(t, result["DB2INST1.EMPLOYEE"], "EMPNO").Statistics; got != nil {
+		t.Errorf("EMPLOYEE.EMPNO Statistics = %#v, want nil", got)
+	}
+}
+
+func TestDb2Profile(t *testing.T) {
+	config := testConfig()
+	config.TargetSchema = []string{"DB2INST1"}
+	config.Profile = true
+	config.ProfileRows = 10
+	config.ProfileValues = true
+	var warnings []Warning
+	config.WarningHandler = func(w Warning) { warnings = append(warnings, w) }
+	result := runExtractor(t, config)
+	// 標本を取れないテーブルは、Warning にしてプロファイルなしで出力します
+	if len(warnings) != 1 || warnings[0].Stage != "profiles" || warnings[0].Object != "DB2INST1.DEPARTMENT" ||
+		!strings.Contains(warnings[0].Message, "SQL0551N") {
+		t.Errorf("Run() warnings = %v, want DEPARTMENT profiles warning", warnings)
+	}
+
+	employee := result["DB2INST1.EMPLOYEE"]
+	tests := []struct {
+		column string
+		want   *ColumnProfile
+	}{
+		{"EMPNO", &ColumnProfile{Rows: 10, Distinct: 20, Min: "000010", Max: "000100",
+			Formats: []string{"INTEGER"}}},
+		{"WORKDEPT", &ColumnProfile{Rows: 10, NullRatio: 0.1, Distinct: 15, Min: "A00", Max: "E21",
+			TopValues: []ValueCount{{"A00", 2}}}},
+		{"HIREDATE", &ColumnProfile{Rows: 10, Distinct: 20, Min: "1979-08-17", Max: "2005-09-30"}},
+		{"SALARY", &ColumnProfile{Rows: 10, Distinct: 20, Min: "49250.00", Max: "152750.00"}},
+		{"BADGEID", nil},
+		{"ANNUAL_SALARY", &ColumnProfile{Rows: 10, NullRatio: 1}},
+	}
+	for _, tt := range tests {
+		if got := findColumn(t, employee, tt.column).Profile; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("EMPLOYEE.%s Profile = %#v, want %#v", tt.column, got, tt.want)
+		}
+	}
+	// DEPARTMENT は標本を取れず、ビューとニックネームは対象外です
+	for _, name := range []string{"DB2INST1.DEPARTMENT", "DB2INST1.VEMP"} {
+		if got := result[name].Columns[0].Profile; got != nil {
+			t.Errorf("%s Profile = %#v, want nil", name, got)
+		}
+	}
+}
+
+func TestDb2OnError(t *testing.T) {
+	// BROKEN は fixture がないため、カタログの照会がすべて失敗します
+	tests := []struct {
+		onError string
+		want    bool
+	}{
+		{OnErrorFail, false},
+		{OnErrorSkipTable, false},
+		{OnErrorKeepGoing, true},
+	}
+	for _, tt := range tests {
+		config := testConfig()
+		config.TargetSchema = []string{"BROKEN", "DB2INST1"}
+		config.OnError = tt.onError
+		result, err := tryExtractor(t, config)
+		var e *ExtractError
+		if !errors.As(err, &e) || e.Schema != "BROKEN" || e.Query == "" {
+			t.Errorf("%s: Run() error = %v, want BROKEN ExtractError", tt.onError, err)
+		}
+		if _, got := result["DB2INST1.EMPLOYEE"]; got != tt.want {
+			t.Errorf("%s: EMPLOYEE written = %v, want %v", tt.onError, got, tt.want)
+		}
+	}
+}
+
+func TestDb2Classify(t *testing.T) {
+	config := testConfig()
+	config.TargetSchema = []string{"DB2INST1"}
+	config.Remarks = []string{"Alias"}
+	config.Classify = true
+	config.Profile = true
+	config.ProfileRows = 10
+	result := runExtractor(t, config)
+
+	employee := result["DB2INST1.EMPLOYEE"]
+	tests := []struct {
+		column string
+		want   *Classification
+	}{
+		{"FIRSTNME", &Classification{"Name", 0.88, []string{"name", "alias"}}},
+		{"LASTNAME", &Classification{"Name", 0.97, []string{"name", "alias"}}},
+		{"PHONENO", &Classification{"Phone", 0.97, []string{"name", "alias"}}},
+		{"EMAIL", &Classification{"Email", 1, []string{"name", "alias", "values"}}},
+		{"EMPNO", nil},
+		{"WORKDEPT", nil},
+	}
+	for _, tt := range tests {
+		if got := findColumn(t, employee, tt.column).Classification; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("EMPLOYEE.%s Classification = %#v, want %#v", tt.column, got, tt.want)
+		}
+	}
+	if got := findColumn(t, result["DB2INST1.DEPARTMENT"], "DEPTNAME").Classification; got != nil {
+		t.Errorf("DEPARTMENT.DEPTNAME Classification = %#v, want nil", got)
+	}
+	want := `-- Classification "DB2INST1"."EMPLOYEE"."EMAIL": Email (1)`
+	if got := employee.ToDDLString(); !strings.Contains(got, want) {
+		t.Errorf("EMPLOYEE ToDDLString() = %s, want %s", got, want)
+	}
+}
+
+func TestDb2ClassifyStatistics(t *testing.T) {
+	for _, values := range []bool{false, true} {
+		config := testConfig()
+		config.TargetSchema = []string{"DB2INST1"}
+		config.Remarks = []string{"Alias"}
+		config.Classify = true
+		config.Statistics = true
+		config.ProfileValues = values
+		employee := runExtractor(t, config)["DB2INST1.EMPLOYEE"]
+
+		// 分類されたカラムの統計情報には、カタログの値の範囲を残しません
+		want := &ColumnStatistics{Distinct: 40, Nulls: 0, AvgLength: 4}
+		if got := findColumn(t, employee, "PHONENO").Statistics; !reflect.DeepEqual(got, want) {
+			t.Errorf("profileValues %v: EMPLOYEE.PHONENO Statistics = %#v, want %#v", values, got, want)
+		}
+		annotated := employee.Annotate([]string{"Statistics"})
+		note := findColumn(t, annotated, "PHONENO").Description
+		if strings.Contains(note, "8953") || strings.Contains(note, "0972") {
+			t.Errorf("profileValues %v: EMPLOYEE.PHONENO description = %s, want no range", values, note)
+		}
+		want = &ColumnStatistics{Distinct: 8, Nulls: 1, AvgLength: 4}
+		if values {
+			want.High, want.Low = "'E11'", "'B01'"
+		}
+		if got := findColumn(t, employee, "WORKDEPT").Statistics; !reflect.DeepEqual(got, want) {
+			t.Errorf("profileValues %v: EMPLOYEE.WORKDEPT Statistics = %#v, want %#v", values, got, want)
+		}
+	}
+}
+
+func TestDb2ProfileValues(t *testing.T) {
+	// sampled は、標本にだけ現れる値です。
+	sampled := []string{"haas@example.com", "CHRISTINE", "HAAS", "3978", "152750.00", "1979-08-17"}
+	for _, values := range []bool{false, true} {
+		config := testConfig()
+		config.TargetSchema = []string{"DB2INST1"}
+		config.Remarks = []string{"Alias"}
+		config.Annotations = []string{"Profile"}
+		config.Classify = true
+		config.Profile = true
+		config.ProfileRows = 10
+		config.ProfileValues = values
+		config.Format = "mashu"
+		output := &bytes.Buffer{}
+		extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
+		extractor.SetConfig(config)
+		extractor.Run(context.Background(), config.Db2DSN(), output)
+		config.Format = "jsonl"
+		jsonl := &bytes.Buffer{}
+		extractor.Run(context.Background(), config.Db2DSN(), jsonl)
+
+		for _, v := range sampled {
+			// 分類されたカラムの値は、ProfileValues が真でも出力しません
+			if !values || v == "haas@example.com" || v == "CHRISTINE" || v == "HAAS" || v == "3978" {
+				for name, out := range map[string]*bytes.Buffer{"mashu": output, "jsonl": jsonl} {
+					if strings.Contains(out.String(), v) {
+						t.Errorf("profileValues %v: %s output contains sampled value %s", values, name, v)
+					}
+				}
+			}
+		}
+		if values && !strings.Contains(jsonl.String(), `"max":"152750.00"`) {
+			t.Errorf("profileValues true: output lacks SALARY max:\n%s", jsonl)
+		}
+	}
 }
@@ -19,11 +19,13 @@ func init() {
 
 // IDb2Extractor は、PostgreSQL から Metadata を抽出します。
 type IDb2Extractor struct {
-	pool   *sql.DB
-	config *Config
+	pool     *sql.DB
+	config   *Config
+	profiler *profiler
 }
 
 // Run は、メータデータの抽出を実行します。MetadataExtractor の実装です。
+// 対象スキーマごとのパイプラインを Config.Parallelism 個まで並行して実行します。
 func (e *IDb2Extractor) Run(ctx context.Context,
 	dsn DataSourceName, out io.Writer) error {
 
@@ -31,15 +33,54 @@ func (e *IDb2Extractor) Run(ctx context.Context,
 	defer cancel()
 
 	var err error
-	e.pool, err = sql.Open(Db2Driver, dsn.DSN())
+	e.pool, err = sql.Open(sqlDriver, dsn.DSN())
 	if err != nil {
 		return err
 	}
 	defer e.pool.Close()
 
-	tableCh := e.extractTables(myCtx)
-	columnCh := e.extractColumns(myCtx, tableCh)
-	return writeCSV(myCtx, columnCh, out)
+	e.profiler = newProfiler(e.pool, e.config)
+	e.countTables(myCtx)
+	output := extractSchemas(myCtx, e.config, func(ctx context.Context, config *Config) <-chan MetadataInProcess {
+		schema := *e
+		schema.config = config
+		return schema.extractSchema(ctx)
+	})
+	return writeMetadata(myCtx, output, out, e.config)
+}
+
+// countTables は、対象スキーマごとに extractTables と同じ種類のテーブルを数え、
+// 進み具合の分母に加えます。
+func (e *IDb2Extractor) countTables(ctx context.Context) {
+	addProgressTotal(ctx, e.pool, e.config, fmt.Sprintf(`
+		SELECT TABLE_SCHEMA, COUNT(*) AS TABLES
+		FROM QSYS2.SYSTABLES
+		WHERE TABLE_TYPE in %s
+		  AND TABLE_SCHEMA in %s
+		GROUP BY TABLE_SCHEMA`,
+		e.config.ObjectTypeInClause(idb2ObjectTypes),
+		e.config.TargetSchemaInClause(),
+	))
+}
+
+// extractSchema は、e.config の対象スキーマのメタデータを抽出するパイプラインを組み立てます。
+func (e *IDb2Extractor) extractSchema(ctx context.Context) <-chan MetadataInProcess {
+	tableCh := e.extractTables(ctx)
+	columnCh := e.extractColumns(ctx, tableCh)
+	keyCh := e.extractKeys(ctx, columnCh)
+	indexCh := e.extractIndexes(ctx, keyCh)
+	viewCh := e.extractViews(ctx, indexCh)
+	statisticsCh := e.extractStatistics(ctx, viewCh)
+	profileCh := e.extractProfiles(ctx, statisticsCh)
+	routineCh := e.extractRoutines(ctx, profileCh)
+	return classifyMetadata(ctx, routineCh, e.config)
+}
+
+// idb2ObjectTypes は、Config.ObjectTypes の種類ごとの QSYS2.SYSTABLES の TABLE_TYPE の値です。
+// 論理ファイル(L)はビュー、物理ファイル(P)と MQT(M)はテーブルとして扱います。
+var idb2ObjectTypes = map[string][]string{
+	"Table": {"M", "P", "T"},
+	"View":  {"L", "V"},
 }
 
 // extractTables は、テーブル情報を抽出します。
@@ -54,39 +95,41 @@ func (e *IDb2Extractor) extractTables(ctx context.Context,
 		cols, err := ColumnList(ctx, e.pool, `
 			SELECT COLUMN_NAME
 			FROM QSYS2.SYSCOLUMNS
-			WHERE TABLE_OWNER='QSYS2'
+			WHERE TABLE_SCHEMA='QSYS2'
 			  AND TABLE_NAME='SYSTABLES'
 			ORDER BY ORDINAL_POSITION`)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
+			send(ctx, output, MetadataInProcess{Err: err})
 			return
 		}
 
 		query := NewQuery(cols, fmt.Sprintf(
 			`FROM QSYS2.SYSTABLES
-			WHERE TYPE != 'A'
-              AND TABLE_OWNER in %s
-			ORDER BY TABLE_OWNER, TABLE_NAME`,
+			WHERE TABLE_TYPE in %s
+			  AND TABLE_SCHEMA in %s
+			ORDER BY TABLE_SCHEMA, TABLE_NAME`,
+			e.config.ObjectTypeInClause(idb2ObjectTypes),
 			e.config.TargetSchemaInClause(),
 		))
 
-		rows, err := query.Exec(ctx, e.pool)
+		next, closeRows, err := query.Open(ctx, e.pool)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
+			send(ctx, output, MetadataInProcess{Err: err})
 			return
 		}
-		defer rows.Close()
+		defer closeRows()
 
-		for rows.Next() {
-			m, err := query.Scan(rows)
+		for {
+			m, err := next()
+			if err == io.EOF {
+				return
+			}
 			if err != nil {
-				output <- MetadataInProcess{Err: err}
+				send(ctx, output, MetadataInProcess{Err: err})
 				return
 			}
-			select {
-			case <-ctx.Done():
+			if !send(ctx, output, MetadataInProcess{Data: *e.toMetadata(m)}) {
 				return
-			case output <- MetadataInProcess{Data: *e.toMetadata(m)}:
 			}
 		}
 	}()
@@ -102,7 +145,7 @@ func (e *IDb2Extractor) toMetadata(m map[string]string) *Metadata {
 	if v, ok := m["TABLE_NAME"]; ok {
 		meta.Name = v
 	}
-	if v, ok := m["TABLE_OWNER"]; ok && meta.Name != "" {
+	if v, ok := m["TABLE_SCHEMA"]; ok && meta.Name != "" {
 		meta.FormalName = strings.TrimSpace(v) + "." + meta.Name
 	}
 	if v, ok := m["LONG_COMMENT"]; ok {
@@ -123,85 +166,40 @@ func (e *IDb2Extractor) toMetadata(m map[string]string) *Metadata {
 func (e *IDb2Extractor) extractColumns(ctx context.Context,
 	input <-chan MetadataInProcess) <-chan MetadataInProcess {
 
-	output := make(chan MetadataInProcess)
-	go func() {
-		defer close(output)
-
+	return joinColumns(ctx, input, "columns", ebcdicOrder, func() (func() (*Column, string, error), func() error, error) {
 		cols, err := ColumnList(ctx, e.pool, `
 			SELECT COLUMN_NAME 
 			FROM QSYS2.SYSCOLUMNS 
-			WHERE TABLE_OWNER='QSYS2'
+			WHERE TABLE_SCHEMA='QSYS2'
 			  AND TABLE_NAME='SYSCOLUMNS'
 			ORDER BY ORDINAL_POSITION`)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
-			return
+			return nil, nil, err
 		}
 
 		query := NewQuery(cols, fmt.Sprintf(
 			`FROM QSYS2.SYSCOLUMNS
-			WHERE TABLE_OWNER in %s
-			ORDER BY TABLE_OWNER, TABLE_NAME, ORDINAL_POSITION`,
+			WHERE TABLE_SCHEMA in %s
+			  AND (TABLE_SCHEMA, TABLE_NAME) in (
+			    SELECT TABLE_SCHEMA, TABLE_NAME FROM QSYS2.SYSTABLES WHERE TABLE_TYPE in %s)
+			ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`,
 			e.config.TargetSchemaInClause(),
+			e.config.ObjectTypeInClause(idb2ObjectTypes),
 		))
 
-		rows, err := query.Exec(ctx, e.pool)
+		next, closeRows, err := query.Open(ctx, e.pool)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
-			return
+			return nil, nil, err
 		}
-		defer rows.Close()
-
-		var meta *Metadata
-		var col *Column
-		var formalName string
-		for rows.Next() {
-			if meta == nil {
-				select {
-				case <-ctx.Done():
-					return
-				case mip := <-input:
-					if mip.Err != nil {
-						output <- mip
-						return
-					}
-					meta = &mip.Data
-					if col != nil {
-						if meta.FormalName != formalName {
-							err = fmt.Errorf("meta.FormalName(%s) != formalName(%s)",
-								meta.FormalName, formalName)
-							output <- MetadataInProcess{Err: err}
-						}
-						meta.Columns = append(meta.Columns, *col)
-					}
-				}
-			}
-			m, err := query.Scan(rows)
+		return func() (*Column, string, error) {
+			m, err := next()
 			if err != nil {
-				output <- MetadataInProcess{Err: err}
-				return
+				return nil, "", err
 			}
-			col, formalName = e.toColumn(m)
-			if meta.FormalName == formalName {
-				meta.Columns = append(meta.Columns, *col)
-			} else {
-				select {
-				case <-ctx.Done():
-					return
-				case output <- MetadataInProcess{Data: *meta}:
-					meta = nil
-				}
-			}
-		}
-		if meta != nil {
-			select {
-			case <-ctx.Done():
-				return
-			case output <- MetadataInProcess{Data: *meta}:
-			}
-		}
-	}()
-	return output
+			col, formalName := e.toColumn(m)
+			return col, formalName, nil
+		}, closeRows, nil
+	})
 }
 
 // toColumn は、information_schema.columns の行の map から Column と
@@ -228,20 +226,45 @@ func (e *IDb2Extractor) toColumn(m map[string]string) (*Column, string) {
 		}
 	}
 
+	if v, ok := m["LENGTH"]; ok {
+		length, err := strconv.Atoi(v)
+		if err == nil {
+			col.SetSize(length, catalogScale(m["NUMERIC_SCALE"]))
+		}
+	}
+	if v, ok := m["CCSID"]; ok {
+		i, err := strconv.Atoi(v)
+		if err == nil {
+			// 文字列の CCSID が 65535 のものは FOR BIT DATA です
+			col.CCSID = i
+			col.ForBitData = i == 65535 && typeKind(col.Type) == typeKindCharacter
+		}
+	}
+
+	// HAS_DEFAULT は、既定値、識別カラム、行変更タイムスタンプを区別します
+	switch m["HAS_DEFAULT"] {
+	case "Y":
+		col.Default = strings.TrimSpace(m["COLUMN_DEFAULT"])
+	case "I", "J":
+		col.Identity = &Identity{
+			Generation: strings.TrimSpace(m["IDENTITY_GENERATION"]),
+			Start:      m["IDENTITY_START"],
+			Increment:  m["IDENTITY_INCREMENT"],
+		}
+	case "E":
+		col.Generated = &Generated{Generation: "ALWAYS", Expression: "ROW CHANGE TIMESTAMP"}
+	case "F":
+		col.Generated = &Generated{Generation: "BY DEFAULT", Expression: "ROW CHANGE TIMESTAMP"}
+	}
+
 	var formalName string
-	if v, ok := m["TABLE_OWNER"]; ok {
+	if v, ok := m["TABLE_SCHEMA"]; ok {
 		formalName = strings.TrimSpace(v)
 	}
 	formalName += "."
 	if v, ok := m["TABLE_NAME"]; ok {
 		formalName += v
 	}
-	if v, ok := m["IS_IDENTITY"]; ok {
-		if v == "YES" {
-			col.KeyType.Constraint = 1
-			col.KeyType.Order = 0
-		}
-	}
 	if v, ok := m["COLUMN_TEXT"]; ok {
 		for _, str := range e.config.Remarks {
 			switch str {
@@ -258,30 +281,455 @@ func (e *IDb2Extractor) toColumn(m map[string]string) (*Column, string) {
 	return col, formalName
 }
 
+// extractKeys は、主キー、一意キー、外部キーを抽出して Metadata に設定します。
+// 識別カラム(IS_IDENTITY)は主キーとは限らないため、制約のカタログから取得します。
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-syscst
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-syskeycst
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-sysrefcst
+func (e *IDb2Extractor) extractKeys(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		constraints := make(map[string][]Constraint)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT C.TABLE_SCHEMA, C.TABLE_NAME, C.CONSTRAINT_NAME, C.CONSTRAINT_TYPE,
+			       K.COLUMN_NAME, K.ORDINAL_POSITION,
+			       RK.TABLE_SCHEMA AS REF_TABLE_SCHEMA,
+			       RK.TABLE_NAME AS REF_TABLE_NAME,
+			       RK.COLUMN_NAME AS REF_COLUMN_NAME
+			FROM QSYS2.SYSCST C
+			JOIN QSYS2.SYSKEYCST K
+			  ON K.CONSTRAINT_SCHEMA = C.CONSTRAINT_SCHEMA
+			 AND K.CONSTRAINT_NAME = C.CONSTRAINT_NAME
+			LEFT JOIN QSYS2.SYSREFCST R
+			  ON R.CONSTRAINT_SCHEMA = C.CONSTRAINT_SCHEMA
+			 AND R.CONSTRAINT_NAME = C.CONSTRAINT_NAME
+			LEFT JOIN QSYS2.SYSKEYCST RK
+			  ON RK.CONSTRAINT_SCHEMA = R.UNIQUE_CONSTRAINT_SCHEMA
+			 AND RK.CONSTRAINT_NAME = R.UNIQUE_CONSTRAINT_NAME
+			 AND RK.ORDINAL_POSITION = K.ORDINAL_POSITION
+			WHERE C.CONSTRAINT_TYPE in ('PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY')
+			  AND C.TABLE_SCHEMA in %s
+			ORDER BY C.TABLE_SCHEMA, C.TABLE_NAME, C.CONSTRAINT_NAME, K.ORDINAL_POSITION`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			var typ int
+			switch m["CONSTRAINT_TYPE"] {
+			case "PRIMARY KEY":
+				typ = 1
+			case "UNIQUE":
+				typ = 2
+			case "FOREIGN KEY":
+				typ = 3
+			}
+			var refTable string
+			if v, ok := m["REF_TABLE_NAME"]; ok {
+				refTable = strings.TrimSpace(m["REF_TABLE_SCHEMA"]) + "." + v
+			}
+			addConstraint(constraints,
+				strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"],
+				m["CONSTRAINT_NAME"], typ, m["COLUMN_NAME"], refTable, m["REF_COLUMN_NAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		accessPaths, err := e.loadKeyedPhysicalFiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			list := constraints[meta.FormalName]
+			if c, ok := accessPaths[meta.FormalName]; ok && !hasPrimaryKey(list) {
+				list = append([]Constraint{c}, list...)
+			}
+			meta.SetConstraints(list)
+		}, nil
+	})
+}
+
+// loadKeyedPhysicalFiles は、DDS で UNIQUE のキー付きアクセスパスを定義した
+// 物理ファイルのキーを、主キー相当の Constraint として返します。
+// DDS のキーは制約のカタログに現れないため、索引の統計情報から取得します。
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-syspartitionindexstat
+func (e *IDb2Extractor) loadKeyedPhysicalFiles(ctx context.Context,
+) (map[string]Constraint, error) {
+
+	result := make(map[string]Constraint)
+	err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+		SELECT TABLE_SCHEMA, TABLE_NAME, INDEX_NAME,
+		       "UNIQUE" AS UNIQUE_RULE, COLUMN_NAMES
+		FROM QSYS2.SYSPARTITIONINDEXSTAT
+		WHERE INDEX_TYPE = 'PHYSICAL'
+		  AND TABLE_SCHEMA in %s
+		ORDER BY TABLE_SCHEMA, TABLE_NAME`,
+		e.config.TargetSchemaInClause(),
+	), func(m map[string]string) error {
+		if !strings.HasPrefix(m["UNIQUE_RULE"], "UNIQUE") {
+			return nil
+		}
+		formalName := strings.TrimSpace(m["TABLE_SCHEMA"]) + "." + m["TABLE_NAME"]
+		// 複数メンバーのファイルはメンバーごとに行があるため、最初の行を使います
+		if _, ok := result[formalName]; ok {
+			return nil
+		}
+		c := Constraint{Name: m["INDEX_NAME"], Type: 1}
+		for _, str := range strings.Split(m["COLUMN_NAMES"], ",") {
+			fields := strings.Fields(str)
+			if len(fields) > 0 {
+				c.Columns = append(c.Columns, fields[0])
+			}
+		}
+		result[formalName] = c
+		return nil
+	})
+	return result, err
+}
+
+// extractIndexes は、索引を抽出して Metadata に設定します。
+// Db2 for i には、クラスター索引と INCLUDE カラムはありません。
+// IS_UNIQUE が D 以外(U, V)の索引を一意索引とし、E のベクトル索引は除きます。
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-sysindexes
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-syskeys
+func (e *IDb2Extractor) extractIndexes(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		indexes := make(map[string][]Index)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT I.TABLE_SCHEMA, I.TABLE_NAME, I.INDEX_SCHEMA, I.INDEX_NAME,
+			       I.IS_UNIQUE, K.COLUMN_NAME, K.COLUMN_POSITION, K.ORDERING
+			FROM QSYS2.SYSINDEXES I
+			JOIN QSYS2.SYSKEYS K
+			  ON K.INDEX_SCHEMA = I.INDEX_SCHEMA
+			 AND K.INDEX_NAME = I.INDEX_NAME
+			WHERE I.IS_UNIQUE != 'E'
+			  AND I.TABLE_SCHEMA in %s
+			ORDER BY I.TABLE_SCHEMA, I.TABLE_NAME, I.INDEX_SCHEMA, I.INDEX_NAME,
+			         K.COLUMN_POSITION`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			addIndexColumn(indexes,
+				strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"],
+				Index{
+					Schema: strings.TrimSpace(m["INDEX_SCHEMA"]),
+					Name:   m["INDEX_NAME"],
+					Unique: m["IS_UNIQUE"] != "D",
+				},
+				m["COLUMN_NAME"], indexOrder(m["ORDERING"]), false)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Indexes = indexes[meta.FormalName]
+		}, nil
+	})
+}
+
+// extractViews は、ビューの定義と参照するテーブルを抽出して Metadata に設定します。
+// ビューのカラムには、定義を解析した元のカラムを設定します。参照するテーブルの
+// カラム名は、抽出の対象外のスキーマのテーブルも含めて SYSCOLUMNS から読み込みます。
+// SYSVIEWDEP には関数や型への依存もあるため、テーブルの種類に絞ります。
+// SYSVIEWS には作成したときの既定のスキーマがないため、修飾されていないテーブル名は
+// SYSVIEWDEP の同じ名前のテーブルで解決します。
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-sysviews
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-sysviewdep
+func (e *IDb2Extractor) extractViews(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		definitions := make(map[string]string)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABLE_SCHEMA, TABLE_NAME, VIEW_DEFINITION
+			FROM QSYS2.SYSVIEWS
+			WHERE TABLE_SCHEMA in %s
+			ORDER BY TABLE_SCHEMA, TABLE_NAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			definitions[strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"]] = m["VIEW_DEFINITION"]
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		baseTables := make(map[string][]string)
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT VIEW_SCHEMA, VIEW_NAME, OBJECT_SCHEMA, OBJECT_NAME
+			FROM QSYS2.SYSVIEWDEP
+			WHERE OBJECT_TYPE in ('ALIAS', 'MATERIALIZED QUERY TABLE', 'TABLE', 'VIEW')
+			  AND VIEW_SCHEMA in %s
+			ORDER BY VIEW_SCHEMA, VIEW_NAME, OBJECT_SCHEMA, OBJECT_NAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			formalName := strings.TrimSpace(m["VIEW_SCHEMA"]) + "." + m["VIEW_NAME"]
+			baseTables[formalName] = append(baseTables[formalName],
+				strings.TrimSpace(m["OBJECT_SCHEMA"])+"."+m["OBJECT_NAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		lineage := newLineageResolver()
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT C.TABLE_SCHEMA, C.TABLE_NAME, C.COLUMN_NAME
+			FROM QSYS2.SYSCOLUMNS C
+			JOIN (SELECT DISTINCT OBJECT_SCHEMA, OBJECT_NAME
+			      FROM QSYS2.SYSVIEWDEP
+			      WHERE OBJECT_TYPE in ('ALIAS', 'MATERIALIZED QUERY TABLE', 'TABLE', 'VIEW')
+			        AND VIEW_SCHEMA in %s) D
+			  ON D.OBJECT_SCHEMA = C.TABLE_SCHEMA AND D.OBJECT_NAME = C.TABLE_NAME
+			ORDER BY C.TABLE_SCHEMA, C.TABLE_NAME, C.ORDINAL_POSITION`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			lineage.addColumn(strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"], m["COLUMN_NAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.SetView(definitions[meta.FormalName], "", baseTables[meta.FormalName])
+			lineage.Apply(meta)
+		}, nil
+	})
+}
+
+// extractStatistics は、Config.Statistics が真の場合に、テーブルの行数、データの
+// サイズ、メンバーの数、最終変更日時と、カラムの統計情報を抽出して Metadata に
+// 設定します。Db2 for i には RUNSTATS がないため、StatsTime は設定しません。
+// カラムの統計情報は、統計マネージャーが収集したカラムだけ設定します。
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-systablestat
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-syscolumnstat
+func (e *IDb2Extractor) extractStatistics(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		if !e.config.Statistics {
+			return func(meta *Metadata) {}, nil
+		}
+		statistics := make(map[string]*TableStatistics)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABLE_SCHEMA, TABLE_NAME, NUMBER_ROWS, NUMBER_PARTITIONS, DATA_SIZE,
+			       LAST_CHANGE_TIMESTAMP
+			FROM QSYS2.SYSTABLESTAT
+			WHERE TABLE_SCHEMA in %s
+			ORDER BY TABLE_SCHEMA, TABLE_NAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			s := newTableStatistics()
+			if i, err := strconv.ParseInt(m["NUMBER_ROWS"], 10, 64); err == nil {
+				s.RowCount = i
+			}
+			s.Size, _ = strconv.ParseInt(m["DATA_SIZE"], 10, 64)
+			s.Partitions, _ = strconv.Atoi(m["NUMBER_PARTITIONS"])
+			s.LastChanged = strings.TrimSpace(m["LAST_CHANGE_TIMESTAMP"])
+			statistics[strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"]] = s
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		columns := make(map[string]*ColumnStatistics)
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, NUMBER_DISTINCT_VALUES, NUMBER_NULLS,
+			       HIGH_VALUE, LOW_VALUE
+			FROM QSYS2.SYSCOLUMNSTAT
+			WHERE TABLE_SCHEMA in %s
+			ORDER BY TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			s := newColumnStatistics()
+			if i, err := strconv.ParseInt(m["NUMBER_DISTINCT_VALUES"], 10, 64); err == nil {
+				s.Distinct = i
+			}
+			if i, err := strconv.ParseInt(m["NUMBER_NULLS"], 10, 64); err == nil {
+				s.Nulls = i
+			}
+			s.High = strings.TrimSpace(m["HIGH_VALUE"])
+			s.Low = strings.TrimSpace(m["LOW_VALUE"])
+			columns[strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"]+"."+m["COLUMN_NAME"]] = s
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Statistics = statistics[meta.FormalName]
+			for i := range meta.Columns {
+				col := &meta.Columns[i]
+				col.Statistics = columns[meta.FormalName+"."+col.Name]
+			}
+		}, nil
+	})
+}
+
+// extractProfiles は、Config.Profile が真の場合に、テーブルのデータを標本にして
+// カラムのプロファイルを作ります。標本は先頭から FETCH FIRST で取り出すため、
+// テーブル全体の傾向と異なる場合があります。ビューは対象外です。標本を取れなかった
+// テーブルは、プロファイルを設定せずに Warning とともに流します。
+func (e *IDb2Extractor) extractProfiles(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return tryEnrichMetadata(ctx, input, "profiles", func() (func(meta *Metadata) error, error) {
+		if !e.config.Profile {
+			return func(meta *Metadata) error { return nil }, nil
+		}
+		cards := make(map[string]int64)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TABLE_SCHEMA, TABLE_NAME, NUMBER_ROWS
+			FROM QSYS2.SYSTABLESTAT
+			WHERE TABLE_SCHEMA in %s`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			card, err := strconv.ParseInt(m["NUMBER_ROWS"], 10, 64)
+			if err != nil {
+				card = -1
+			}
+			cards[strings.TrimSpace(m["TABLE_SCHEMA"])+"."+m["TABLE_NAME"]] = card
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := e.profiler
+		return func(meta *Metadata) error {
+			if card, ok := cards[meta.FormalName]; ok {
+				return p.profile(ctx, meta, "", card)
+			}
+			return nil
+		}, nil
+	})
+}
+
+// extractRoutines は、ストアドプロシージャーとユーザー定義関数を抽出し、
+// テーブルの後に MetaType が Model の Metadata として流します。
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-sysroutines
+// https://www.ibm.com/docs/ja/i/7.5?topic=views-sysparms
+func (e *IDb2Extractor) extractRoutines(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return appendMetadata(ctx, input, func() ([]Metadata, error) {
+		if !e.config.IncludesObjectType("Routine") {
+			return nil, nil
+		}
+		routines := newRoutineList()
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT SPECIFIC_SCHEMA, SPECIFIC_NAME, ROUTINE_SCHEMA, ROUTINE_NAME, ROUTINE_TYPE,
+			       ROUTINE_BODY, EXTERNAL_LANGUAGE, ROUTINE_DEFINITION, LONG_COMMENT
+			FROM QSYS2.SYSROUTINES
+			WHERE ROUTINE_SCHEMA in %s
+			ORDER BY ROUTINE_SCHEMA, ROUTINE_NAME, SPECIFIC_NAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			meta := Metadata{
+				Name:       m["ROUTINE_NAME"],
+				FormalName: strings.TrimSpace(m["ROUTINE_SCHEMA"]) + "." + m["ROUTINE_NAME"],
+				MetaType:   2, // core.ModelData
+				Lang:       e.config.Lang,
+				Routine: &Routine{
+					Type:         routineType(m["ROUTINE_TYPE"]),
+					SpecificName: m["SPECIFIC_NAME"],
+					Language:     strings.TrimSpace(m["EXTERNAL_LANGUAGE"]),
+					Definition:   m["ROUTINE_DEFINITION"],
+				},
+			}
+			// SQL ルーチンは、EXTERNAL_LANGUAGE が NULL です
+			if strings.TrimSpace(m["ROUTINE_BODY"]) == "SQL" {
+				meta.Routine.Language = "SQL"
+			}
+			for _, str := range e.config.Remarks {
+				switch str {
+				case "Alias":
+					meta.Alias = m["LONG_COMMENT"]
+				case "Description":
+					meta.Description = m["LONG_COMMENT"]
+				}
+			}
+			routines.add(strings.TrimSpace(m["SPECIFIC_SCHEMA"]), meta)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// 表関数の結果のカラム(ROW_TYPE 'C')は、パラメーターの後に並べます
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT SPECIFIC_SCHEMA, SPECIFIC_NAME, PARAMETER_NAME, PARAMETER_MODE, ROW_TYPE,
+			       ORDINAL_POSITION, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION,
+			       NUMERIC_SCALE, DATETIME_PRECISION, CCSID, LONG_COMMENT
+			FROM QSYS2.SYSPARMS
+			WHERE ROW_TYPE in ('C', 'P')
+			  AND SPECIFIC_SCHEMA in %s
+			ORDER BY SPECIFIC_SCHEMA, SPECIFIC_NAME, ROW_TYPE DESC, ORDINAL_POSITION`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			routines.addParameter(strings.TrimSpace(m["SPECIFIC_SCHEMA"]), m["SPECIFIC_NAME"],
+				e.toParameter(m))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return routines.Metadata(), nil
+	})
+}
+
+// toParameter は、QSYS2.SYSPARMS の行の map から Column を作ります。
+// 長さは文字数または 10 進数の精度、位取りは 10 進数の位取りまたは秒の小数部の桁数です。
+func (e *IDb2Extractor) toParameter(m map[string]string) Column {
+	col := Column{
+		Name: m["PARAMETER_NAME"],
+		Type: strings.TrimSpace(m["DATA_TYPE"]),
+	}
+	col.Parameter = strings.TrimSpace(m["PARAMETER_MODE"])
+	if m["ROW_TYPE"] != "P" {
+		col.Parameter = parameterMode(m["ROW_TYPE"])
+	}
+	if col.Name == "" {
+		col.Name = col.Parameter
+	}
+	length, err := strconv.Atoi(m["CHARACTER_MAXIMUM_LENGTH"])
+	if err != nil {
+		length, _ = strconv.Atoi(m["NUMERIC_PRECISION"])
+	}
+	scale := catalogScale(m["NUMERIC_SCALE"])
+	if scale < 0 {
+		scale = catalogScale(m["DATETIME_PRECISION"])
+	}
+	col.SetSize(length, scale)
+	ccsid, err := strconv.Atoi(m["CCSID"])
+	if err == nil {
+		col.CCSID = ccsid
+		col.ForBitData = ccsid == 65535 && typeKind(col.Type) == typeKindCharacter
+	}
+	for _, str := range e.config.Remarks {
+		switch str {
+		case "Alias":
+			col.Alias = m["LONG_COMMENT"]
+		case "Description":
+			col.Description = m["LONG_COMMENT"]
+		}
+	}
+	return col
+}
+
 // FindSchema は、スキーマの一覧を取得する。
 func (e *IDb2Extractor) FindSchema(ctx context.Context, dsn DataSourceName) ([]string, error) {
-	db, err := sql.Open(Db2Driver, dsn.DSN())
+	db, err := sql.Open(sqlDriver, dsn.DSN())
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	rows, err := db.QueryContext(ctx, `
-	    SELECT CREATOR
+	return ColumnList(ctx, db, `
+	    SELECT TABLE_SCHEMA
 		FROM QSYS2.SYSTABLES
-		GROUP BY TABLE_OWNER`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	result := []string{}
-	for rows.Next() {
-		var column string
-		rows.Scan(&column)
-		result = append(result, column)
-	}
-	return result, nil
+		GROUP BY TABLE_SCHEMA`)
 }
 
 func (e *IDb2Extractor) SetConfig(config *Config) {
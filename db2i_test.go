@@ -5,8 +5,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -14,7 +16,7 @@ func TestIDb2Run(t *testing.T) {
 	ctx := context.Background()
 	config := &Config{
 		Hostname:     "localhost",
-		Database:     "SAMPLE",
+		Database:     "IBMI",
 		Port:         50000,
 		UserID:       "db2inst1",
 		Password:     "password",
@@ -22,28 +24,24 @@ func TestIDb2Run(t *testing.T) {
 		Remarks:      []string{"Alias", "Description"},
 		CSVFile:      "testdata/mashu.csv",
 		SystemSchema: "QSYS2",
-		TargetSchema: []string{"QSYS2   "},
-		// TargetSchema: []string{"DB2INST1"},
-	}
-	output, err := os.Create(config.CSVFile)
-	if err != nil {
-		t.Fatalf("os.Open() error :%s", err)
+		TargetSchema: []string{"DB2INST1"},
 	}
-	defer output.Close()
+	output := &bytes.Buffer{}
 
 	extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
 	extractor.SetConfig(config)
-	err = extractor.Run(ctx, config.Db2DSN(), output)
+	err := extractor.Run(ctx, config.Db2DSN(), output)
 	if err != nil {
 		t.Fatalf("Run() error :%s", err)
 	}
+	assertGolden(t, "testdata/golden/db2i.csv", output.Bytes())
 }
 
 func TestIDb2FindScehma(t *testing.T) {
 	ctx := context.Background()
 	config := &Config{
 		Hostname:     "localhost",
-		Database:     "SAMPLE",
+		Database:     "IBMI",
 		Port:         50000,
 		UserID:       "db2inst1",
 		Password:     "password",
@@ -59,5 +57,134 @@ func TestIDb2FindScehma(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Run() error :%s", err)
 	}
-	t.Logf("%#v", list)
+	want := []string{"DB2INST1", "QGPL", "QSYS2", "SYSIBM"}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("FindSchema() = %#v, want %#v", list, want)
+	}
+}
+
+func TestIDb2Keys(t *testing.T) {
+	config := testConfig()
+	config.Database = "IBMI"
+	config.SystemSchema = "QSYS2"
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+
+	tests := []struct {
+		table  string
+		column string
+		want   KeyType
+	}{
+		{"DB2INST1.DEPARTMENT", "DEPTNO", KeyType{Constraint: 1, Order: 1}},
+		{"DB2INST1.EMPLOYEE", "EMPNO", KeyType{Constraint: 1, Order: 1}},
+		{"DB2INST1.EMPLOYEE", "LASTNAME", KeyType{Constraint: 2, Order: 1}},
+		{"DB2INST1.EMPLOYEE", "FIRSTNME", KeyType{Constraint: 2, Order: 2}},
+		{"DB2INST1.EMPLOYEE", "ROW_ID", KeyType{}},
+	}
+	for _, tt := range tests {
+		col := findColumn(t, result[tt.table], tt.column)
+		if col.KeyType != tt.want {
+			t.Errorf("%s.%s KeyType = %#v, want %#v", tt.table, tt.column, col.KeyType, tt.want)
+		}
+	}
+	if got := result["DB2INST1.DEPARTMENT"].Constraints[0].Name; got != "DEPARTMENT" {
+		t.Errorf("DDS access path constraint name = %s, want DEPARTMENT", got)
+	}
+}
+
+func TestIDb2ColumnDefaults(t *testing.T) {
+	config := testConfig()
+	config.Database = "IBMI"
+	config.SystemSchema = "QSYS2"
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+
+	col := findColumn(t, result["DB2INST1.EMPLOYEE"], "ROW_ID")
+	want := "GENERATED ALWAYS AS IDENTITY (START WITH 1, INCREMENT BY 1)"
+	if got := col.GenerationClause(); got != want {
+		t.Errorf("ROW_ID GenerationClause() = %s, want %s", got, want)
+	}
+	col = findColumn(t, result["DB2INST1.DEPARTMENT"], "LOCATION")
+	if col.Default != "'TOKYO'" || col.Identity != nil || col.Generated != nil {
+		t.Errorf("LOCATION = %#v, want default 'TOKYO'", col)
+	}
+}
+
+func TestIDb2ViewDDL(t *testing.T) {
+	config := testConfig()
+	config.Database = "IBMI"
+	config.SystemSchema = "QSYS2"
+	config.TargetSchema = []string{"DB2INST1"}
+	m := runExtractor(t, config)["DB2INST1.VEMP"]
+
+	want := `CREATE VIEW "DB2INST1"."VEMP" AS SELECT E.EMPNO, D.DEPTNAME FROM DB2INST1.EMPLOYEE E ` +
+		"LEFT JOIN DB2INST1.DEPARTMENT D ON E.WORKDEPT = D.DEPTNO;\n"
+	if got := m.ToDDLString(); !strings.HasPrefix(got, want) {
+		t.Errorf("VEMP ToDDLString() = %s, want prefix %s", got, want)
+	}
+}
+
+func TestIDb2Routines(t *testing.T) {
+	config := testConfig()
+	config.Database = "IBMI"
+	config.SystemSchema = "QSYS2"
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+
+	m := result["DB2INST1.PAYROLL"]
+	if m.MetaType != 2 {
+		t.Errorf("PAYROLL MetaType = %d, want 2", m.MetaType)
+	}
+	want := &Routine{Type: "PROCEDURE", SpecificName: "PAYROLL", Language: "RPGLE"}
+	if !reflect.DeepEqual(m.Routine, want) {
+		t.Errorf("PAYROLL Routine = %#v, want %#v", m.Routine, want)
+	}
+	tests := []struct {
+		table     string
+		column    string
+		parameter string
+		sqlType   string
+		order     int
+	}{
+		{"DB2INST1.PAYROLL", "PERIOD", "IN", "TIMESTAMP(6)", 1},
+		{"DB2INST1.PAYROLL", "TOTAL", "INOUT", "DECIMAL(11,2)", 2},
+		{"DB2INST1.EMPCOUNT", "DEPTNUM", "IN", "CHAR(3)", 1},
+		{"DB2INST1.EMPCOUNT", "RETURN", "RETURN", "INTEGER", 2},
+	}
+	for _, tt := range tests {
+		col := findColumn(t, result[tt.table], tt.column)
+		if col.Parameter != tt.parameter || col.SQLType() != tt.sqlType || col.Order != tt.order {
+			t.Errorf("%s.%s = %s %s #%d, want %s %s #%d", tt.table, tt.column,
+				col.Parameter, col.SQLType(), col.Order, tt.parameter, tt.sqlType, tt.order)
+		}
+	}
+
+	ddl := `-- PROCEDURE "DB2INST1"."PAYROLL" (IN "PERIOD" TIMESTAMP(6), INOUT "TOTAL" DECIMAL(11,2))` + "\n"
+	if got := m.ToDDLString(); !strings.HasPrefix(got, ddl) {
+		t.Errorf("PAYROLL ToDDLString() = %s, want prefix %s", got, ddl)
+	}
+}
+
+func TestIDb2Statistics(t *testing.T) {
+	config := testConfig()
+	config.Database = "IBMI"
+	config.SystemSchema = "QSYS2"
+	config.TargetSchema = []string{"DB2INST1"}
+	config.Statistics = true
+	config.ProfileValues = true
+	result := runExtractor(t, config)
+
+	want := &TableStatistics{RowCount: 0, Pages: -1, Size: 8192,
+		LastChanged: "2024-04-01 10:00:00.000000", Partitions: 1}
+	if got := result["DB2INST1.DEPARTMENT"].Statistics; !reflect.DeepEqual(got, want) {
+		t.Errorf("DEPARTMENT Statistics = %#v, want %#v", got, want)
+	}
+	if got := want.Notes()[0]; got != "Rows: 0 (empty)" {
+		t.Errorf("DEPARTMENT Notes()[0] = %s, want Rows: 0 (empty)", got)
+	}
+
+	column := &ColumnStatistics{Distinct: 8, Nulls: 1, High: "E21", Low: "A00"}
+	if got := findColumn(t, result["DB2INST1.EMPLOYEE"], "WORKDEPT").Statistics; !reflect.DeepEqual(got, column) {
+		t.Errorf("EMPLOYEE.WORKDEPT Statistics = %#v, want %#v", got, column)
+	}
 }
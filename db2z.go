@@ -19,11 +19,13 @@ func init() {
 
 // ZDb2Extractor は、PostgreSQL から Metadata を抽出します。
 type ZDb2Extractor struct {
-	pool   *sql.DB
-	config *Config
+	pool     *sql.DB
+	config   *Config
+	profiler *profiler
 }
 
 // Run は、メータデータの抽出を実行します。MetadataExtractor の実装です。
+// 対象スキーマごとのパイプラインを Config.Parallelism 個まで並行して実行します。
 func (e *ZDb2Extractor) Run(ctx context.Context,
 	dsn DataSourceName, out io.Writer) error {
 
@@ -31,15 +33,54 @@ func (e *ZDb2Extractor) Run(ctx context.Context,
 	defer cancel()
 
 	var err error
-	e.pool, err = sql.Open(Db2Driver, dsn.DSN())
+	e.pool, err = sql.Open(sqlDriver, dsn.DSN())
 	if err != nil {
 		return err
 	}
 	defer e.pool.Close()
 
-	tableCh := e.extractTables(myCtx)
-	columnCh := e.extractColumns(myCtx, tableCh)
-	return writeCSV(myCtx, columnCh, out)
+	e.profiler = newProfiler(e.pool, e.config)
+	e.countTables(myCtx)
+	output := extractSchemas(myCtx, e.config, func(ctx context.Context, config *Config) <-chan MetadataInProcess {
+		schema := *e
+		schema.config = config
+		return schema.extractSchema(ctx)
+	})
+	return writeMetadata(myCtx, output, out, e.config)
+}
+
+// countTables は、対象スキーマごとに extractTables と同じ種類のテーブルを数え、
+// 進み具合の分母に加えます。
+func (e *ZDb2Extractor) countTables(ctx context.Context) {
+	addProgressTotal(ctx, e.pool, e.config, fmt.Sprintf(`
+		SELECT CREATOR, COUNT(*) AS TABLES
+		FROM SYSIBM.SYSTABLES
+		WHERE TYPE in %s
+		  AND CREATOR in %s
+		GROUP BY CREATOR`,
+		e.config.ObjectTypeInClause(zdb2ObjectTypes),
+		e.config.TargetSchemaInClause(),
+	))
+}
+
+// extractSchema は、e.config の対象スキーマのメタデータを抽出するパイプラインを組み立てます。
+func (e *ZDb2Extractor) extractSchema(ctx context.Context) <-chan MetadataInProcess {
+	tableCh := e.extractTables(ctx)
+	columnCh := e.extractColumns(ctx, tableCh)
+	keyCh := e.extractKeys(ctx, columnCh)
+	indexCh := e.extractIndexes(ctx, keyCh)
+	viewCh := e.extractViews(ctx, indexCh)
+	statisticsCh := e.extractStatistics(ctx, viewCh)
+	profileCh := e.extractProfiles(ctx, statisticsCh)
+	routineCh := e.extractRoutines(ctx, profileCh)
+	return classifyMetadata(ctx, routineCh, e.config)
+}
+
+// zdb2ObjectTypes は、Config.ObjectTypes の種類ごとの SYSIBM.SYSTABLES の TYPE の値です。
+// 別名(A)以外のビューではないものは、すべてテーブルとして扱います。
+var zdb2ObjectTypes = map[string][]string{
+	"Table": {"C", "D", "G", "H", "M", "P", "R", "T", "X"},
+	"View":  {"V"},
 }
 
 // extractTables は、テーブル情報を抽出します。
@@ -58,35 +99,37 @@ func (e *ZDb2Extractor) extractTables(ctx context.Context,
 			  AND TBNAME='SYSTABLES'
 			ORDER BY COLNO`)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
+			send(ctx, output, MetadataInProcess{Err: err})
 			return
 		}
 
 		query := NewQuery(cols, fmt.Sprintf(
 			`FROM SYSIBM.SYSTABLES
-			WHERE TYPE != 'A'
+			WHERE TYPE in %s
               AND CREATOR in %s
 			ORDER BY CREATOR, NAME`,
+			e.config.ObjectTypeInClause(zdb2ObjectTypes),
 			e.config.TargetSchemaInClause(),
 		))
 
-		rows, err := query.Exec(ctx, e.pool)
+		next, closeRows, err := query.Open(ctx, e.pool)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
+			send(ctx, output, MetadataInProcess{Err: err})
 			return
 		}
-		defer rows.Close()
+		defer closeRows()
 
-		for rows.Next() {
-			m, err := query.Scan(rows)
+		for {
+			m, err := next()
+			if err == io.EOF {
+				return
+			}
 			if err != nil {
-				output <- MetadataInProcess{Err: err}
+				send(ctx, output, MetadataInProcess{Err: err})
 				return
 			}
-			select {
-			case <-ctx.Done():
+			if !send(ctx, output, MetadataInProcess{Data: *e.toMetadata(m)}) {
 				return
-			case output <- MetadataInProcess{Data: *e.toMetadata(m)}:
 			}
 		}
 	}()
@@ -123,10 +166,7 @@ func (e *ZDb2Extractor) toMetadata(m map[string]string) *Metadata {
 func (e *ZDb2Extractor) extractColumns(ctx context.Context,
 	input <-chan MetadataInProcess) <-chan MetadataInProcess {
 
-	output := make(chan MetadataInProcess)
-	go func() {
-		defer close(output)
-
+	return joinColumns(ctx, input, "columns", binaryOrder, func() (func() (*Column, string, error), func() error, error) {
 		cols, err := ColumnList(ctx, e.pool, `
 			SELECT NAME 
 			FROM SYSIBM.SYSCOLUMNS 
@@ -134,74 +174,32 @@ func (e *ZDb2Extractor) extractColumns(ctx context.Context,
 			  AND TBNAME='SYSCOLUMNS'
 			ORDER BY COLNO`)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
-			return
+			return nil, nil, err
 		}
 
 		query := NewQuery(cols, fmt.Sprintf(
 			`FROM SYSIBM.SYSCOLUMNS
 			WHERE TBCREATOR in %s
+			  AND (TBCREATOR, TBNAME) in (
+			    SELECT CREATOR, NAME FROM SYSIBM.SYSTABLES WHERE TYPE in %s)
 			ORDER BY TBCREATOR, TBNAME, COLNO`,
 			e.config.TargetSchemaInClause(),
+			e.config.ObjectTypeInClause(zdb2ObjectTypes),
 		))
 
-		rows, err := query.Exec(ctx, e.pool)
+		next, closeRows, err := query.Open(ctx, e.pool)
 		if err != nil {
-			output <- MetadataInProcess{Err: err}
-			return
+			return nil, nil, err
 		}
-		defer rows.Close()
-
-		var meta *Metadata
-		var col *Column
-		var formalName string
-		for rows.Next() {
-			if meta == nil {
-				select {
-				case <-ctx.Done():
-					return
-				case mip := <-input:
-					if mip.Err != nil {
-						output <- mip
-						return
-					}
-					meta = &mip.Data
-					if col != nil {
-						if meta.FormalName != formalName {
-							err = fmt.Errorf("meta.FormalName(%s) != formalName(%s)",
-								meta.FormalName, formalName)
-							output <- MetadataInProcess{Err: err}
-						}
-						meta.Columns = append(meta.Columns, *col)
-					}
-				}
-			}
-			m, err := query.Scan(rows)
+		return func() (*Column, string, error) {
+			m, err := next()
 			if err != nil {
-				output <- MetadataInProcess{Err: err}
-				return
-			}
-			col, formalName = e.toColumn(m)
-			if meta.FormalName == formalName {
-				meta.Columns = append(meta.Columns, *col)
-			} else {
-				select {
-				case <-ctx.Done():
-					return
-				case output <- MetadataInProcess{Data: *meta}:
-					meta = nil
-				}
+				return nil, "", err
 			}
-		}
-		if meta != nil {
-			select {
-			case <-ctx.Done():
-				return
-			case output <- MetadataInProcess{Data: *meta}:
-			}
-		}
-	}()
-	return output
+			col, formalName := e.toColumn(m)
+			return col, formalName, nil
+		}, closeRows, nil
+	})
 }
 
 // toColumn は、information_schema.columns の行の map から Column と
@@ -228,6 +226,43 @@ func (e *ZDb2Extractor) toColumn(m map[string]string) (*Column, string) {
 		}
 	}
 
+	if v, ok := m["LENGTH"]; ok {
+		length, err := strconv.Atoi(v)
+		if err == nil {
+			// LOB の LENGTH はポインターの長さのため、LENGTH2 の最大長を使います
+			if i, err := strconv.Atoi(m["LENGTH2"]); err == nil && i > 0 {
+				length = i
+			}
+			col.SetSize(length, catalogScale(m["SCALE"]))
+		}
+	}
+	if v, ok := m["CCSID"]; ok {
+		i, err := strconv.Atoi(v)
+		if err == nil {
+			col.CCSID = i
+		}
+	}
+	if v, ok := m["FOREIGNKEY"]; ok {
+		// 文字列の FOREIGNKEY が B のものは FOR BIT DATA です
+		col.ForBitData = v == "B" && typeKind(col.Type) == typeKindCharacter
+	}
+
+	if v, ok := m["DEFAULT"]; ok {
+		e.setDefault(col, v, m["DEFAULTVALUE"])
+	}
+
+	// 統計情報を収集していないカラムは COLCARDF が -1 です。HIGH2KEY と LOW2KEY は
+	// 内部形式のため、FOR BIT DATA でない文字列のカラムだけ設定します。
+	if f, err := strconv.ParseFloat(m["COLCARDF"], 64); e.config.Statistics && err == nil && f >= 0 {
+		s := newColumnStatistics()
+		s.Distinct = int64(f)
+		if typeKind(col.Type) == typeKindCharacter && !col.ForBitData {
+			s.High = strings.TrimSpace(m["HIGH2KEY"])
+			s.Low = strings.TrimSpace(m["LOW2KEY"])
+		}
+		col.Statistics = s
+	}
+
 	var formalName string
 	if v, ok := m["TBCREATOR"]; ok {
 		formalName = strings.TrimSpace(v)
@@ -236,13 +271,6 @@ func (e *ZDb2Extractor) toColumn(m map[string]string) (*Column, string) {
 	if v, ok := m["TBNAME"]; ok {
 		formalName += v
 	}
-	if v, ok := m["KEYSEQ"]; ok {
-		i, err := strconv.Atoi(v)
-		if err == nil {
-			col.KeyType.Constraint = 1
-			col.KeyType.Order = i
-		}
-	}
 	if v, ok := m["REMARKS"]; ok {
 		for _, str := range e.config.Remarks {
 			switch str {
@@ -259,30 +287,413 @@ func (e *ZDb2Extractor) toColumn(m map[string]string) (*Column, string) {
 	return col, formalName
 }
 
+// setDefault は、SYSCOLUMNS の DEFAULT と DEFAULTVALUE から既定値、識別カラム、
+// 生成カラムを設定します。
+//   - I, J: 識別カラム(GENERATED ALWAYS, BY DEFAULT)
+//   - A, D: ROWID、行変更タイムスタンプ(GENERATED ALWAYS, BY DEFAULT)
+//   - S, U: CURRENT SQLID, USER
+//   - N, B, Y: 既定値なし、型の既定値、NULL(DEFAULTVALUE があればその値)
+//   - その他: DEFAULTVALUE の値
+//
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-syscolumns
+func (e *ZDb2Extractor) setDefault(col *Column, code string, value string) {
+	switch code {
+	case "I":
+		col.Identity = &Identity{Generation: "ALWAYS"}
+	case "J":
+		col.Identity = &Identity{Generation: "BY DEFAULT"}
+	case "A", "D":
+		col.Generated = &Generated{Generation: generation(code)}
+		if typeKind(col.Type) == typeKindTimestamp {
+			col.Generated.Expression = "ROW CHANGE TIMESTAMP"
+		}
+	case "S":
+		col.Default = "CURRENT SQLID"
+	case "U":
+		col.Default = "USER"
+	case "N":
+	default:
+		col.Default = strings.TrimSpace(value)
+	}
+}
+
+// extractKeys は、主キーと一意キーを抽出して Metadata に設定します。
+// SYSCOLUMNS の KEYSEQ はテーブルの種類によって設定されず、一意索引も
+// 含まないため、キーを強制する索引(UNIQUERULE)とそのキーから取得します。
+// 索引の名前を制約の名前とし、キーの順序は COLSEQ の順です。
+//   - P: 主キーを強制する索引
+//   - C: UNIQUE 制約を強制する索引
+//   - U: 一意索引
+//
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysindexes
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-syskeys
+func (e *ZDb2Extractor) extractKeys(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		constraints := make(map[string][]Constraint)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT I.TBCREATOR, I.TBNAME, I.NAME, I.UNIQUERULE,
+			       K.COLNAME, K.COLSEQ
+			FROM SYSIBM.SYSINDEXES I
+			JOIN SYSIBM.SYSKEYS K
+			  ON K.IXCREATOR = I.CREATOR
+			 AND K.IXNAME = I.NAME
+			WHERE I.UNIQUERULE in ('P', 'C', 'U')
+			  AND I.TBCREATOR in %s
+			ORDER BY I.TBCREATOR, I.TBNAME, I.NAME, K.COLSEQ`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			typ := 2
+			if m["UNIQUERULE"] == "P" {
+				typ = 1
+			}
+			addConstraint(constraints,
+				strings.TrimSpace(m["TBCREATOR"])+"."+m["TBNAME"],
+				m["NAME"], typ, m["COLNAME"], "", "")
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.SetConstraints(constraints[meta.FormalName])
+		}, nil
+	})
+}
+
+// extractIndexes は、索引を抽出して Metadata に設定します。
+// UNIQUE_COUNT が 0 より大きい索引では、COLSEQ がそれを超えるカラムが
+// INCLUDE カラムです。
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysindexes
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-syskeys
+func (e *ZDb2Extractor) extractIndexes(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		indexes := make(map[string][]Index)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT I.TBCREATOR, I.TBNAME, I.CREATOR, I.NAME,
+			       I.UNIQUERULE, I.CLUSTERING, I.UNIQUE_COUNT,
+			       K.COLNAME, K.COLSEQ, K.ORDERING
+			FROM SYSIBM.SYSINDEXES I
+			JOIN SYSIBM.SYSKEYS K
+			  ON K.IXCREATOR = I.CREATOR
+			 AND K.IXNAME = I.NAME
+			WHERE I.TBCREATOR in %s
+			ORDER BY I.TBCREATOR, I.TBNAME, I.CREATOR, I.NAME, K.COLSEQ`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			uniqueCount, _ := strconv.Atoi(m["UNIQUE_COUNT"])
+			colseq, _ := strconv.Atoi(m["COLSEQ"])
+			addIndexColumn(indexes,
+				strings.TrimSpace(m["TBCREATOR"])+"."+m["TBNAME"],
+				Index{
+					Schema:    strings.TrimSpace(m["CREATOR"]),
+					Name:      m["NAME"],
+					Unique:    m["UNIQUERULE"] != "D",
+					Clustered: m["CLUSTERING"] == "Y",
+				},
+				m["COLNAME"], indexOrder(m["ORDERING"]), uniqueCount > 0 && colseq > uniqueCount)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Indexes = indexes[meta.FormalName]
+		}, nil
+	})
+}
+
+// extractViews は、ビューの定義と参照するテーブルを抽出して Metadata に設定します。
+// ビューのカラムには、定義を解析した元のカラムを設定します。参照するテーブルの
+// カラム名は、抽出の対象外のスキーマのテーブルも含めて SYSCOLUMNS から読み込みます。
+// SYSVIEWS の TEXT は SEQNO の順に分割されているため、連結します。
+// 修飾されていないテーブル名は、SYSENVIRONMENT の CURRENT_SCHEMA のスキーマで解決します。
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysviews
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysenvironment
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysviewdep
+func (e *ZDb2Extractor) extractViews(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		definitions := make(map[string]string)
+		qualifiers := make(map[string]string)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT V.CREATOR, V.NAME, V.SEQNO, E.CURRENT_SCHEMA, V.TEXT
+			FROM SYSIBM.SYSVIEWS V
+			LEFT JOIN SYSIBM.SYSENVIRONMENT E ON E.ENVID = V.ENVID
+			WHERE V.CREATOR in %s
+			ORDER BY V.CREATOR, V.NAME, V.SEQNO`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			formalName := strings.TrimSpace(m["CREATOR"]) + "." + m["NAME"]
+			definitions[formalName] += m["TEXT"]
+			qualifiers[formalName] = strings.TrimSpace(m["CURRENT_SCHEMA"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		baseTables := make(map[string][]string)
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT DCREATOR, DNAME, BCREATOR, BNAME
+			FROM SYSIBM.SYSVIEWDEP
+			WHERE DTYPE = 'V'
+			  AND BTYPE in ('A', 'G', 'M', 'T', 'V')
+			  AND DCREATOR in %s
+			ORDER BY DCREATOR, DNAME, BCREATOR, BNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			formalName := strings.TrimSpace(m["DCREATOR"]) + "." + m["DNAME"]
+			baseTables[formalName] = append(baseTables[formalName],
+				strings.TrimSpace(m["BCREATOR"])+"."+m["BNAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		lineage := newLineageResolver()
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT C.TBCREATOR, C.TBNAME, C.NAME
+			FROM SYSIBM.SYSCOLUMNS C
+			JOIN (SELECT DISTINCT BCREATOR, BNAME
+			      FROM SYSIBM.SYSVIEWDEP
+			      WHERE DTYPE = 'V'
+			        AND BTYPE in ('A', 'G', 'M', 'T', 'V')
+			        AND DCREATOR in %s) D
+			  ON D.BCREATOR = C.TBCREATOR AND D.BNAME = C.TBNAME
+			ORDER BY C.TBCREATOR, C.TBNAME, C.COLNO`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			lineage.addColumn(strings.TrimSpace(m["TBCREATOR"])+"."+m["TBNAME"], m["NAME"])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.SetView(definitions[meta.FormalName], qualifiers[meta.FormalName], baseTables[meta.FormalName])
+			lineage.Apply(meta)
+		}, nil
+	})
+}
+
+// extractStatistics は、Config.Statistics が真の場合に、RUNSTATS の統計情報と
+// リアルタイム統計のサイズ、パーティションの数、最終変更日時、パーティション・キーを
+// 抽出して Metadata に設定します。リアルタイム統計はテーブルスペース単位のため、
+// 複数のテーブルを持つテーブルスペースでは Size はテーブルスペース全体の値です。
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-systables
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-systablespacestats
+func (e *ZDb2Extractor) extractStatistics(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return enrichMetadata(ctx, input, func() (func(meta *Metadata), error) {
+		if !e.config.Statistics {
+			return func(meta *Metadata) {}, nil
+		}
+		statistics := make(map[string]*TableStatistics)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT T.CREATOR, T.NAME, T.CARDF, T.NPAGESF, T.STATSTIME, T.DBNAME, T.TSNAME,
+			       COUNT(S.PARTITION) AS PARTITIONS, SUM(S.SPACE) AS SPACE,
+			       MAX(S.LASTDATACHANGE) AS LASTDATACHANGE
+			FROM SYSIBM.SYSTABLES T
+			LEFT JOIN SYSIBM.SYSTABLESPACESTATS S ON S.DBNAME = T.DBNAME AND S.NAME = T.TSNAME
+			WHERE T.TYPE in ('C', 'D', 'G', 'H', 'M', 'P', 'R', 'T', 'X')
+			  AND T.CREATOR in %s
+			GROUP BY T.CREATOR, T.NAME, T.CARDF, T.NPAGESF, T.STATSTIME, T.DBNAME, T.TSNAME
+			ORDER BY T.CREATOR, T.NAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			s := newTableStatistics()
+			if f, err := strconv.ParseFloat(m["CARDF"], 64); err == nil {
+				s.RowCount = int64(f)
+			}
+			if f, err := strconv.ParseFloat(m["NPAGESF"], 64); err == nil {
+				s.Pages = int64(f)
+			}
+			// SPACE は KB 単位です
+			if i, err := strconv.ParseInt(m["SPACE"], 10, 64); err == nil {
+				s.Size = i * 1024
+			}
+			s.StatsTime = statsTime(m["STATSTIME"])
+			s.LastChanged = strings.TrimSpace(m["LASTDATACHANGE"])
+			s.Tablespace = strings.TrimSpace(m["DBNAME"]) + "." + strings.TrimSpace(m["TSNAME"])
+			s.Partitions, _ = strconv.Atoi(m["PARTITIONS"])
+			statistics[strings.TrimSpace(m["CREATOR"])+"."+m["NAME"]] = s
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT TBCREATOR, TBNAME, NAME
+			FROM SYSIBM.SYSCOLUMNS
+			WHERE PARTKEY_COLSEQ > 0
+			  AND TBCREATOR in %s
+			ORDER BY TBCREATOR, TBNAME, PARTKEY_COLSEQ`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			if s, ok := statistics[strings.TrimSpace(m["TBCREATOR"])+"."+m["TBNAME"]]; ok {
+				s.PartitionKeys = append(s.PartitionKeys, m["NAME"])
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) {
+			meta.Statistics = statistics[meta.FormalName]
+		}, nil
+	})
+}
+
+// extractProfiles は、Config.Profile が真の場合に、テーブルのデータを標本にして
+// カラムのプロファイルを作ります。標本は先頭から FETCH FIRST で取り出すため、
+// テーブル全体の傾向と異なる場合があります。ビューは対象外です。標本を取れなかった
+// テーブルは、プロファイルを設定せずに Warning とともに流します。
+func (e *ZDb2Extractor) extractProfiles(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return tryEnrichMetadata(ctx, input, "profiles", func() (func(meta *Metadata) error, error) {
+		if !e.config.Profile {
+			return func(meta *Metadata) error { return nil }, nil
+		}
+		cards := make(map[string]int64)
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT CREATOR, NAME, CARDF
+			FROM SYSIBM.SYSTABLES
+			WHERE TYPE in ('H', 'M', 'R', 'T')
+			  AND CREATOR in %s`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			card := int64(-1)
+			if f, err := strconv.ParseFloat(m["CARDF"], 64); err == nil && f >= 0 {
+				card = int64(f)
+			}
+			cards[strings.TrimSpace(m["CREATOR"])+"."+m["NAME"]] = card
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := e.profiler
+		return func(meta *Metadata) error {
+			if card, ok := cards[meta.FormalName]; ok {
+				return p.profile(ctx, meta, "", card)
+			}
+			return nil
+		}, nil
+	})
+}
+
+// extractRoutines は、ストアドプロシージャーとユーザー定義関数を抽出し、
+// テーブルの後に MetaType が Model の Metadata として流します。
+// システムが生成した関数(ORIGIN 'S')は除きます。SYSROUTINES には SQL ルーチンの
+// 本体がないため、Routine.Definition は設定しません。
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysroutines
+// https://www.ibm.com/docs/ja/db2-for-zos/13?topic=tables-sysparms
+func (e *ZDb2Extractor) extractRoutines(ctx context.Context,
+	input <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	return appendMetadata(ctx, input, func() ([]Metadata, error) {
+		if !e.config.IncludesObjectType("Routine") {
+			return nil, nil
+		}
+		routines := newRoutineList()
+		err := QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT SCHEMA, NAME, SPECIFICNAME, ROUTINETYPE, LANGUAGE, REMARKS
+			FROM SYSIBM.SYSROUTINES
+			WHERE ROUTINETYPE in ('F', 'P')
+			  AND ORIGIN <> 'S'
+			  AND SCHEMA in %s
+			ORDER BY SCHEMA, NAME, SPECIFICNAME`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			schema := strings.TrimSpace(m["SCHEMA"])
+			meta := Metadata{
+				Name:       m["NAME"],
+				FormalName: schema + "." + m["NAME"],
+				MetaType:   2, // core.ModelData
+				Lang:       e.config.Lang,
+				Routine: &Routine{
+					Type:         routineType(m["ROUTINETYPE"]),
+					SpecificName: m["SPECIFICNAME"],
+					Language:     strings.TrimSpace(m["LANGUAGE"]),
+				},
+			}
+			for _, str := range e.config.Remarks {
+				switch str {
+				case "Alias":
+					meta.Alias = m["REMARKS"]
+				case "Description":
+					meta.Description = m["REMARKS"]
+				}
+			}
+			routines.add(schema, meta)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// 表関数の結果のカラム(ROWTYPE 'C')は、パラメーターの後に並べます
+		err = QueryRows(ctx, e.pool, fmt.Sprintf(`
+			SELECT SCHEMA, SPECIFICNAME, PARMNAME, ROWTYPE, ORDINAL,
+			       TYPESCHEMA, TYPENAME, LENGTH, SCALE, CCSID
+			FROM SYSIBM.SYSPARMS
+			WHERE ROWTYPE in ('B', 'C', 'O', 'P')
+			  AND SCHEMA in %s
+			ORDER BY SCHEMA, SPECIFICNAME, CASE ROWTYPE WHEN 'C' THEN 1 ELSE 0 END, ORDINAL`,
+			e.config.TargetSchemaInClause(),
+		), func(m map[string]string) error {
+			routines.addParameter(strings.TrimSpace(m["SCHEMA"]), m["SPECIFICNAME"], e.toParameter(m))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return routines.Metadata(), nil
+	})
+}
+
+// toParameter は、SYSIBM.SYSPARMS の行の map から Column を作ります。
+// SYSPARMS には説明がないため、Alias と Description は設定しません。
+func (e *ZDb2Extractor) toParameter(m map[string]string) Column {
+	col := Column{
+		Name:      m["PARMNAME"],
+		Type:      strings.TrimSpace(m["TYPESCHEMA"]) + "." + strings.TrimSpace(m["TYPENAME"]),
+		Parameter: parameterMode(m["ROWTYPE"]),
+	}
+	if col.Name == "" {
+		col.Name = col.Parameter
+	}
+	length, err := strconv.Atoi(m["LENGTH"])
+	if err == nil {
+		col.SetSize(length, catalogScale(m["SCALE"]))
+	}
+	ccsid, err := strconv.Atoi(m["CCSID"])
+	if err == nil {
+		col.CCSID = ccsid
+	}
+	return col
+}
+
 // FindSchema は、スキーマの一覧を取得する。
 func (e *ZDb2Extractor) FindSchema(ctx context.Context, dsn DataSourceName) ([]string, error) {
-	db, err := sql.Open(Db2Driver, dsn.DSN())
+	db, err := sql.Open(sqlDriver, dsn.DSN())
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	rows, err := db.QueryContext(ctx, `
+	return ColumnList(ctx, db, `
 	    SELECT CREATOR
 		FROM SYSIBM.SYSTABLES
 		GROUP BY CREATOR`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	result := []string{}
-	for rows.Next() {
-		var column string
-		rows.Scan(&column)
-		result = append(result, column)
-	}
-	return result, nil
 }
 
 func (e *ZDb2Extractor) SetConfig(config *Config) {
@@ -5,8 +5,9 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"os"
+	"reflect"
 	"testing"
 )
 
@@ -14,7 +15,7 @@ func TestZDb2Run(t *testing.T) {
 	ctx := context.Background()
 	config := &Config{
 		Hostname:     "localhost",
-		Database:     "SAMPLE",
+		Database:     "ZOS",
 		Port:         50000,
 		UserID:       "db2inst1",
 		Password:     "password",
@@ -23,27 +24,23 @@ func TestZDb2Run(t *testing.T) {
 		CSVFile:      "testdata/mashu.csv",
 		SystemSchema: "SYSIBM",
 		TargetSchema: []string{"DB2INST1"},
-		// TargetSchema: []string{"SYSIBM  "},
 	}
-	output, err := os.Create(config.CSVFile)
-	if err != nil {
-		t.Fatalf("os.Open() error :%s", err)
-	}
-	defer output.Close()
+	output := &bytes.Buffer{}
 
 	extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
 	extractor.SetConfig(config)
-	err = extractor.Run(ctx, config.Db2DSN(), output)
+	err := extractor.Run(ctx, config.Db2DSN(), output)
 	if err != nil {
 		t.Fatalf("Run() error :%s", err)
 	}
+	assertGolden(t, "testdata/golden/db2z.csv", output.Bytes())
 }
 
 func TestZDb2FindScehma(t *testing.T) {
 	ctx := context.Background()
 	config := &Config{
 		Hostname:     "localhost",
-		Database:     "SAMPLE",
+		Database:     "ZOS",
 		Port:         50000,
 		UserID:       "db2inst1",
 		Password:     "password",
@@ -59,5 +56,112 @@ func TestZDb2FindScehma(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Run() error :%s", err)
 	}
-	t.Logf("%#v", list)
+	want := []string{"DB2INST1", "DSN8C10", "SYSIBM"}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("FindSchema() = %#v, want %#v", list, want)
+	}
+}
+
+func TestZDb2Keys(t *testing.T) {
+	config := testConfig()
+	config.Database = "ZOS"
+	config.SystemSchema = "SYSIBM"
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+
+	tests := []struct {
+		table  string
+		column string
+		want   KeyType
+	}{
+		{"DB2INST1.DEPARTMENT", "DEPTNO", KeyType{Constraint: 1, Order: 1}},
+		{"DB2INST1.DEPARTMENT", "DEPTNAME", KeyType{}},
+		{"DB2INST1.EMPLOYEE", "EMPNO", KeyType{Constraint: 1, Order: 1}},
+		{"DB2INST1.EMPLOYEE", "LASTNAME", KeyType{Constraint: 2, Order: 1}},
+		{"DB2INST1.EMPLOYEE", "FIRSTNME", KeyType{Constraint: 2, Order: 2}},
+		{"DB2INST1.EMPLOYEE", "WORKDEPT", KeyType{Constraint: 2, Order: 2}},
+		{"DB2INST1.VEMP", "EMPNO", KeyType{}},
+	}
+	for _, tt := range tests {
+		col := findColumn(t, result[tt.table], tt.column)
+		if col.KeyType != tt.want {
+			t.Errorf("%s.%s KeyType = %#v, want %#v", tt.table, tt.column, col.KeyType, tt.want)
+		}
+	}
+}
+
+func TestZDb2Indexes(t *testing.T) {
+	config := testConfig()
+	config.Database = "ZOS"
+	config.SystemSchema = "SYSIBM"
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+
+	want := []Index{
+		{Schema: "DB2INST1", Name: "XEMP1", Unique: true,
+			Columns: []IndexColumn{{Name: "EMPNO", Order: "ASC"}}, Include: []string{"LASTNAME"}},
+		{Schema: "DB2INST1", Name: "XEMP2", Unique: true,
+			Columns: []IndexColumn{{Name: "LASTNAME", Order: "ASC"}, {Name: "FIRSTNME", Order: "DESC"}}},
+		{Schema: "DB2INST1", Name: "XEMP4", Clustered: true,
+			Columns: []IndexColumn{{Name: "WORKDEPT", Order: "ASC"}}},
+	}
+	if got := result["DB2INST1.EMPLOYEE"].Indexes; !reflect.DeepEqual(got, want) {
+		t.Errorf("EMPLOYEE Indexes = %#v, want %#v", got, want)
+	}
+	if got := result["DB2INST1.VEMP"].Indexes; got != nil {
+		t.Errorf("VEMP Indexes = %#v, want nil", got)
+	}
+}
+
+func TestZDb2Views(t *testing.T) {
+	config := testConfig()
+	config.Database = "ZOS"
+	config.SystemSchema = "SYSIBM"
+	config.TargetSchema = []string{"DB2INST1"}
+	result := runExtractor(t, config)
+
+	want := &View{
+		Definition: "CREATE VIEW DB2INST1.VEMP AS SELECT E.EMPNO, D.DEPTNAME FROM DB2INST1.EMPLOYEE E " +
+			"LEFT JOIN DB2INST1.DEPARTMENT D ON E.WORKDEPT = D.DEPTNO",
+		Qualifier:  "DB2INST1",
+		BaseTables: []string{"DB2INST1.DEPARTMENT", "DB2INST1.EMPLOYEE"},
+	}
+	if got := result["DB2INST1.VEMP"].View; !reflect.DeepEqual(got, want) {
+		t.Errorf("VEMP View = %#v, want %#v", got, want)
+	}
+	if got := result["DB2INST1.EMPLOYEE"].View; got != nil {
+		t.Errorf("EMPLOYEE View = %#v, want nil", got)
+	}
+
+	lineage := []ColumnSource{{Table: "DB2INST1.DEPARTMENT", Column: "DEPTNAME"}}
+	if got := findColumn(t, result["DB2INST1.VEMP"], "DEPTNAME").Lineage; !reflect.DeepEqual(got, lineage) {
+		t.Errorf("VEMP.DEPTNAME Lineage = %v, want %v", got, lineage)
+	}
+}
+
+func TestZDb2Statistics(t *testing.T) {
+	config := testConfig()
+	config.Database = "ZOS"
+	config.SystemSchema = "SYSIBM"
+	config.TargetSchema = []string{"DB2INST1"}
+	config.Statistics = true
+	result := runExtractor(t, config)
+
+	tests := []struct {
+		table string
+		want  *TableStatistics
+	}{
+		{"DB2INST1.DEPARTMENT", &TableStatistics{RowCount: 14, Pages: 1, Size: 720 * 1024,
+			StatsTime: "2024-04-02-03.00.00.000000", LastChanged: "2024-04-01-10.00.00.000000",
+			Tablespace: "DSNDB04.DEPARTME", Partitions: 1}},
+		{"DB2INST1.EMPLOYEE", &TableStatistics{RowCount: -1, Pages: -1, Size: 2160 * 1024,
+			LastChanged: "2024-04-03-12.34.56.000000", Tablespace: "DSNDB04.EMPLOYEE",
+			Partitions: 3, PartitionKeys: []string{"EMPNO"}}},
+		{"DB2INST1.VEMP", nil},
+	}
+	for _, tt := range tests {
+		if got := result[tt.table].Statistics; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s Statistics = %#v, want %#v", tt.table, got, tt.want)
+		}
+	}
 }
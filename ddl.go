@@ -0,0 +1,218 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	registerWriter("ddl", newDDLWriter)
+}
+
+// DDLWriter は、Metadata を CREATE TABLE 文、CREATE INDEX 文と COMMENT 文で出力します。
+// ビュー、別名、ニックネーム、シーケンス、ルーチンは、それぞれの CREATE 文で出力します。
+// 抽出したメタデータの確認用で、元の DDL を完全には再現しません。
+type DDLWriter struct {
+	out io.Writer
+}
+
+// newDDLWriter は、DDLWriter を作ります。NewMetadataWriter の実装です。
+func newDDLWriter(out io.Writer, config *Config) MetadataWriter {
+	return &DDLWriter{out: out}
+}
+
+// Write は、Metadata を 1 件書き出します。MetadataWriter の実装です。
+func (w *DDLWriter) Write(m *Metadata) error {
+	_, err := io.WriteString(w.out, m.ToDDLString())
+	return err
+}
+
+// Close は、出力を完了します。MetadataWriter の実装です。
+func (w *DDLWriter) Close() error {
+	return nil
+}
+
+// ToDDLString は、Metadata の DDL 表現を返す
+// ビューは、カタログに記録された定義を CREATE VIEW 文として出力します。
+func (m Metadata) ToDDLString() string {
+	table := m.quotedName()
+	if m.Routine != nil {
+		// 多重定義されたルーチンの正式名は、スキーマ.特定名 です
+		schema, ok := strings.CutSuffix(m.FormalName, "."+m.Name)
+		if !ok {
+			schema = strings.TrimSuffix(m.FormalName, "."+m.Routine.SpecificName)
+		}
+		return m.Routine.ToDDLString(schema, m.Name, m.Columns, m.Description)
+	}
+	buf := strings.Builder{}
+	object := "TABLE"
+	switch {
+	case m.View != nil:
+		buf.WriteString(m.View.ToDDLString(table))
+	case m.BaseObject != "":
+		object = "ALIAS"
+		fmt.Fprintf(&buf, "CREATE ALIAS %s FOR %s;\n", table, quoteFormalName(m.BaseObject))
+	case m.Remote != nil:
+		object = "NICKNAME"
+		fmt.Fprintf(&buf, "CREATE NICKNAME %s FOR %s.%s.%s;\n", table, quoteIdentifier(m.Remote.Server),
+			quoteIdentifier(m.Remote.Schema), quoteIdentifier(m.Remote.Name))
+	case m.Sequence != nil:
+		object = "SEQUENCE"
+		fmt.Fprintf(&buf, "CREATE SEQUENCE %s %s;\n", table, m.Sequence.Clause())
+	default:
+		buf.WriteString(m.createTable(table))
+	}
+	if m.Description != "" {
+		fmt.Fprintf(&buf, "COMMENT ON %s %s IS %s;\n", object, table, quoteString(m.Description))
+	}
+	for _, c := range m.Columns {
+		// 別名のカラムは参照先のカラムのため、COMMENT 文は出力しません
+		if c.Description != "" && m.BaseObject == "" {
+			fmt.Fprintf(&buf, "COMMENT ON COLUMN %s.%s IS %s;\n",
+				table, quoteIdentifier(c.Name), quoteString(c.Description))
+		}
+	}
+	for _, idx := range m.Indexes {
+		buf.WriteString(idx.ToDDLString(table))
+	}
+	// 分類は DDL で表せないため、コメントで出力します
+	for _, c := range m.Columns {
+		if c.Classification != nil && m.BaseObject == "" {
+			fmt.Fprintf(&buf, "-- Classification %s.%s: %s\n",
+				table, quoteIdentifier(c.Name), c.Classification.String())
+		}
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// createTable は、Metadata の CREATE TABLE 文を返す
+func (m Metadata) createTable(table string) string {
+	lines := []string{}
+	for _, c := range m.Columns {
+		str := fmt.Sprintf("%s %s", quoteIdentifier(c.Name), c.SQLType())
+		if c.Mode == 1 {
+			str += " NOT NULL"
+		}
+		if clause := c.GenerationClause(); clause != "" {
+			str += " " + clause
+		} else if c.Default != "" {
+			str += " DEFAULT " + c.Default
+		}
+		lines = append(lines, str)
+	}
+	for _, c := range m.Constraints {
+		str := fmt.Sprintf("CONSTRAINT %s ", quoteIdentifier(c.Name))
+		switch c.Type {
+		case 1:
+			str += "PRIMARY KEY"
+		case 2:
+			str += "UNIQUE"
+		case 3:
+			str += "FOREIGN KEY"
+		}
+		str += " (" + quoteIdentifiers(c.Columns) + ")"
+		if c.RefTable != "" {
+			str += fmt.Sprintf(" REFERENCES %s (%s)",
+				quoteFormalName(c.RefTable), quoteIdentifiers(c.RefColumns))
+		}
+		lines = append(lines, str)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n  %s\n);\n", table, strings.Join(lines, ",\n  "))
+}
+
+// ToDDLString は、table に対する View の CREATE VIEW 文を返す
+// Db2 for i の定義は SELECT 文だけのため、CREATE VIEW を補います。
+func (v View) ToDDLString(table string) string {
+	definition := strings.TrimRight(strings.TrimSpace(v.Definition), ";")
+	if !strings.HasPrefix(strings.ToUpper(definition), "CREATE ") {
+		definition = fmt.Sprintf("CREATE VIEW %s AS %s", table, definition)
+	}
+	return definition + ";\n"
+}
+
+// ToDDLString は、スキーマ schema の name のルーチンの CREATE 文と COMMENT 文を返す
+// 定義がカタログにないルーチンは、パラメーターの一覧をコメントとして出力します。
+func (r Routine) ToDDLString(schema string, name string, parameters []Column, description string) string {
+	buf := strings.Builder{}
+	definition := strings.TrimRight(strings.TrimSpace(r.Definition), ";")
+	if strings.HasPrefix(strings.ToUpper(definition), "CREATE ") {
+		buf.WriteString(definition + ";\n")
+	} else {
+		list := []string{}
+		for _, p := range parameters {
+			list = append(list, fmt.Sprintf("%s %s %s", p.Parameter, quoteIdentifier(p.Name), p.SQLType()))
+		}
+		fmt.Fprintf(&buf, "-- %s %s.%s (%s)\n", r.Type, quoteIdentifier(schema),
+			quoteIdentifier(name), strings.Join(list, ", "))
+	}
+	if description != "" {
+		fmt.Fprintf(&buf, "COMMENT ON SPECIFIC %s %s.%s IS %s;\n", r.Type,
+			quoteIdentifier(schema), quoteIdentifier(r.SpecificName), quoteString(description))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// ToDDLString は、table に対する Index の CREATE INDEX 文を返す
+func (idx Index) ToDDLString(table string) string {
+	str := "CREATE "
+	if idx.Unique {
+		str += "UNIQUE "
+	}
+	columns := make([]string, len(idx.Columns))
+	for i, c := range idx.Columns {
+		columns[i] = quoteIdentifier(c.Name) + " " + c.Order
+	}
+	str += fmt.Sprintf("INDEX %s.%s ON %s (%s)", quoteIdentifier(idx.Schema),
+		quoteIdentifier(idx.Name), table, strings.Join(columns, ", "))
+	if len(idx.Include) > 0 {
+		str += " INCLUDE (" + quoteIdentifiers(idx.Include) + ")"
+	}
+	if idx.Clustered {
+		str += " CLUSTER"
+	}
+	return str + ";\n"
+}
+
+// quotedName は、FormalName のスキーマと Name を区切り識別子にして返します。
+func (m Metadata) quotedName() string {
+	schema, ok := strings.CutSuffix(m.FormalName, "."+m.Name)
+	if !ok || schema == "" {
+		return quoteIdentifier(m.FormalName)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(m.Name)
+}
+
+// quoteFormalName は、スキーマ.名前 の形式の正式名を区切り識別子にして返します。
+func quoteFormalName(formalName string) string {
+	schema, name, ok := strings.Cut(formalName, ".")
+	if !ok {
+		return quoteIdentifier(formalName)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(name)
+}
+
+// quoteIdentifier は、名前を " で囲んだ区切り識別子にします。
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteIdentifiers は、名前を区切り識別子にしてカンマで連結します。
+func quoteIdentifiers(names []string) string {
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = quoteIdentifier(name)
+	}
+	return strings.Join(list, ", ")
+}
+
+// quoteString は、文字列を ' で囲んだ文字列定数にします。
+func quoteString(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
@@ -0,0 +1,289 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriverName は、テスト用のカタログドライバーの名前です。
+const fakeDriverName = "fake_ibm_db"
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestMain(m *testing.M) {
+	sql.Register(fakeDriverName, &fakeDriver{})
+	sqlDriver = fakeDriverName
+	os.Exit(m.Run())
+}
+
+// fakeFixture は、記録されたカタログの結果セットです。
+// Match は、空白を正規化した SQL 文に含まれる文字列です。
+type fakeFixture struct {
+	Match   string          `json:"match"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	Error   string          `json:"error"`
+}
+
+// fakeDriver は、testdata/fakedb/<DATABASE>.json の結果セットを返す
+// database/sql のドライバーです。
+type fakeDriver struct {
+	mu       sync.Mutex
+	fixtures map[string][]fakeFixture
+}
+
+// Open は、driver.Driver の実装です。DSN の DATABASE で fixture を選びます。
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	database := ""
+	for _, kv := range strings.Split(name, ";") {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && strings.EqualFold(k, "DATABASE") {
+			database = v
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fixtures == nil {
+		d.fixtures = make(map[string][]fakeFixture)
+	}
+	fixtures, ok := d.fixtures[database]
+	if !ok {
+		data, err := os.ReadFile(filepath.Join("testdata", "fakedb", database+".json"))
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &fixtures); err != nil {
+			return nil, fmt.Errorf("%s.json: %w", database, err)
+		}
+		for i := range fixtures {
+			fixtures[i].Match = normalizeSQL(fixtures[i].Match)
+		}
+		d.fixtures[database] = fixtures
+	}
+	return &fakeConn{fixtures: fixtures}, nil
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// normalizeSQL は、SQL 文の空白を 1 文字にまとめます。
+func normalizeSQL(query string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(query, " "))
+}
+
+// fakeConn は、fixture を検索するコネクションです。
+type fakeConn struct {
+	fixtures []fakeFixture
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakedb: transactions are not supported")
+}
+
+// QueryContext は、driver.QueryerContext の実装です。
+func (c *fakeConn) QueryContext(ctx context.Context,
+	query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stmt := normalizeSQL(query)
+	for _, f := range c.fixtures {
+		if !strings.Contains(stmt, f.Match) {
+			continue
+		}
+		if f.Error != "" {
+			return nil, errors.New(f.Error)
+		}
+		return newFakeRows(stmt, f), nil
+	}
+	return nil, fmt.Errorf("fakedb: no fixture for query: %s", stmt)
+}
+
+// fakeStmt は、Prepare された SQL 文です。
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("fakedb: exec is not supported")
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+var selectList = regexp.MustCompile(`^SELECT (.+?) FROM `)
+
+// fakeRows は、fixture の行を SELECT 句のカラム順に射影して返します。
+type fakeRows struct {
+	columns []string
+	index   []int
+	rows    [][]interface{}
+	pos     int
+}
+
+// newFakeRows は、SELECT 句のカラムがすべて fixture にあれば射影し、
+// なければ fixture のカラムをそのまま返す fakeRows を作ります。
+func newFakeRows(stmt string, f fakeFixture) *fakeRows {
+	r := &fakeRows{columns: f.Columns, rows: f.Rows}
+	for i := range f.Columns {
+		r.index = append(r.index, i)
+	}
+	sm := selectList.FindStringSubmatch(stmt)
+	if sm == nil {
+		return r
+	}
+	names := strings.Split(sm[1], ",")
+	index := make([]int, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := -1
+		for i, c := range f.Columns {
+			if strings.EqualFold(c, name) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return r
+		}
+		index = append(index, found)
+	}
+	r.columns = make([]string, len(index))
+	for i, n := range index {
+		r.columns[i] = f.Columns[n]
+	}
+	r.index = index
+	return r
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.pos]
+	r.pos++
+	for i, n := range r.index {
+		if n >= len(row) {
+			dest[i] = nil
+			continue
+		}
+		switch v := row[n].(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				dest[i] = int64(v)
+			} else {
+				dest[i] = v
+			}
+		default:
+			dest[i] = v
+		}
+	}
+	return nil
+}
+
+// assertGolden は、got を golden ファイルと比較します。
+// -update が指定された場合は golden ファイルを書き換えます。
+func assertGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(golden, got, 0666); err != nil {
+			t.Fatalf("os.WriteFile() error :%s", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error :%s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output mismatch with %s\n--- got ---\n%s\n--- want ---\n%s",
+			golden, got, want)
+	}
+}
+
+// runExtractor は、config の systemSchema の MetadataExtractor を JSON Lines 形式で
+// 実行し、出力を FormalName をキーとする map で返します。
+func runExtractor(t *testing.T, config *Config) map[string]Metadata {
+	t.Helper()
+	result, err := tryExtractor(t, config)
+	if err != nil {
+		t.Fatalf("Run() error :%s", err)
+	}
+	return result
+}
+
+// tryExtractor は、Run のエラーとともに、出力された Metadata を返す runExtractor です。
+func tryExtractor(t *testing.T, config *Config) (map[string]Metadata, error) {
+	t.Helper()
+	config.Format = "jsonl"
+	output := &bytes.Buffer{}
+	extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
+	extractor.SetConfig(config)
+	runErr := extractor.Run(context.Background(), config.Db2DSN(), output)
+
+	result := make(map[string]Metadata)
+	dec := json.NewDecoder(output)
+	for dec.More() {
+		var m Metadata
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("json.Decoder.Decode() error :%s", err)
+		}
+		result[m.FormalName] = m
+	}
+	return result, runErr
+}
+
+// findColumn は、名前が一致する Column を返します。
+func findColumn(t *testing.T, m Metadata, name string) Column {
+	t.Helper()
+	for _, c := range m.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("%s has no column %s", m.FormalName, name)
+	return Column{}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+func init() {
+	registerWriter("jsonl", newJSONLinesWriter)
+	registerWriter("json", newJSONWriter)
+}
+
+// JSONLinesWriter は、Metadata を 1 行 1 件の JSON Lines で出力します。
+type JSONLinesWriter struct {
+	enc *json.Encoder
+}
+
+// newJSONLinesWriter は、JSONLinesWriter を作ります。NewMetadataWriter の実装です。
+func newJSONLinesWriter(out io.Writer, config *Config) MetadataWriter {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	return &JSONLinesWriter{enc: enc}
+}
+
+// Write は、Metadata を 1 件書き出します。MetadataWriter の実装です。
+func (w *JSONLinesWriter) Write(m *Metadata) error {
+	return w.enc.Encode(m)
+}
+
+// Close は、出力を完了します。MetadataWriter の実装です。
+func (w *JSONLinesWriter) Close() error {
+	return nil
+}
+
+// JSONWriter は、Metadata の配列を整形した JSON で出力します。
+// 全件をメモリに保持しないよう、1 件ずつ配列の要素として書き出します。
+type JSONWriter struct {
+	out   io.Writer
+	buf   bytes.Buffer
+	enc   *json.Encoder
+	count int
+}
+
+// newJSONWriter は、JSONWriter を作ります。NewMetadataWriter の実装です。
+func newJSONWriter(out io.Writer, config *Config) MetadataWriter {
+	w := &JSONWriter{out: out}
+	w.enc = json.NewEncoder(&w.buf)
+	w.enc.SetEscapeHTML(false)
+	w.enc.SetIndent("  ", "  ")
+	return w
+}
+
+// Write は、Metadata を 1 件書き出します。MetadataWriter の実装です。
+func (w *JSONWriter) Write(m *Metadata) error {
+	w.buf.Reset()
+	if w.count == 0 {
+		w.buf.WriteString("[\n  ")
+	} else {
+		w.buf.WriteString(",\n  ")
+	}
+	err := w.enc.Encode(m)
+	if err != nil {
+		return err
+	}
+	// Encode が付ける末尾の改行は、次の要素の区切りで出力します
+	w.buf.Truncate(w.buf.Len() - 1)
+	w.count++
+	_, err = w.out.Write(w.buf.Bytes())
+	return err
+}
+
+// Close は、配列を閉じて出力を完了します。MetadataWriter の実装です。
+func (w *JSONWriter) Close() error {
+	str := "\n]\n"
+	if w.count == 0 {
+		str = "[]\n"
+	}
+	_, err := io.WriteString(w.out, str)
+	return err
+}
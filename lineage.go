@@ -0,0 +1,917 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ColumnSource は、カラムの値の元になったテーブルのカラムです。
+type ColumnSource struct {
+	// Table は、テーブルの正式名
+	Table string `json:"table"`
+	// Column は、カラム名
+	Column string `json:"column"`
+}
+
+// String は、テーブルの正式名とカラム名の文字列表現を返す
+func (s ColumnSource) String() string {
+	return s.Table + "." + s.Column
+}
+
+// ColumnLineage は、ビューの出力カラムと、その元になったカラムです。
+type ColumnLineage struct {
+	// Name は、出力カラム名です。式で別名がない場合は空です。
+	Name string
+	// Sources は、出力カラムの元になったカラムです。解決できない場合は空です。
+	Sources []ColumnSource
+}
+
+// ParseViewLineage は、ビューの定義(CREATE VIEW 文、または SELECT 文)を解析し、
+// 出力カラムごとの元のカラムを返します。
+// 修飾されていないテーブル名は defaultSchema のテーブルとします。columnsOf は
+// テーブルの正式名からカラム名の一覧を返す関数で、修飾されていないカラム名と * の
+// 解決に使います。不明な場合は nil を返します。
+// 別名、結合、副照会、共通表式、UNION、CASE 式、関数の引数を解決します。
+func ParseViewLineage(definition string, defaultSchema string,
+	columnsOf func(table string) []string) ([]ColumnLineage, error) {
+
+	return parseViewLineage(definition, func(name string) string {
+		return defaultSchema + "." + name
+	}, columnsOf)
+}
+
+// parseViewLineage は、ParseViewLineage の実装です。qualify は、修飾されていない
+// テーブル名を正式名にする関数です。
+func parseViewLineage(definition string, qualify func(name string) string,
+	columnsOf func(table string) []string) ([]ColumnLineage, error) {
+
+	tokens, err := tokenizeSQL(definition)
+	if err != nil {
+		return nil, err
+	}
+	p := &lineageParser{
+		tokens:    tokens,
+		qualify:   qualify,
+		columnsOf: columnsOf,
+	}
+	names := p.parseCreateView()
+	columns, err := p.parseQuery(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ColumnLineage, len(columns))
+	for i, c := range columns {
+		result[i] = ColumnLineage{Name: c.name, Sources: c.sources}
+		if i < len(names) {
+			result[i].Name = names[i]
+		}
+	}
+	return result, nil
+}
+
+// SetLineage は、ビューの各 Column に元のカラムを設定します。
+// 出力カラムの数が Columns と一致すれば順番で、一致しなければ名前で対応付けます。
+func (m *Metadata) SetLineage(lineage []ColumnLineage) {
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		col.Lineage = nil
+		if len(lineage) == len(m.Columns) {
+			col.Lineage = lineage[i].Sources
+			continue
+		}
+		for _, l := range lineage {
+			if l.Name == col.Name {
+				col.Lineage = l.Sources
+				break
+			}
+		}
+	}
+}
+
+// lineageResolver は、ビューが参照するテーブルのカラム名を保持し、ビューの
+// カラムの元のカラムを解決します。カラム名はカタログから読み込むため、参照先の
+// テーブルが流れる順番や、参照先のスキーマが抽出の対象かどうかに依存しません。
+type lineageResolver struct {
+	columns map[string][]string
+}
+
+// newLineageResolver は、lineageResolver を作ります。
+func newLineageResolver() *lineageResolver {
+	return &lineageResolver{columns: make(map[string][]string)}
+}
+
+// addColumn は、正式名が table のテーブルのカラム column を記録します。
+// カラムの順番に呼びます。
+func (r *lineageResolver) addColumn(table, column string) {
+	r.columns[table] = append(r.columns[table], column)
+}
+
+// Apply は、meta がビューであれば Column.Lineage を設定します。
+// 定義を解析できないビューは、Column.Lineage を設定しません。
+// 修飾されていないテーブル名は、View.Qualifier のスキーマのテーブルとします。
+// Qualifier がカタログにない場合は、同じ名前の参照するテーブルを探し、なければ
+// ビューのスキーマのテーブルとします。
+func (r *lineageResolver) Apply(meta *Metadata) {
+	if meta.View == nil {
+		return
+	}
+	qualify := func(name string) string {
+		if meta.View.Qualifier != "" {
+			return meta.View.Qualifier + "." + name
+		}
+		schema := strings.TrimSuffix(meta.FormalName, "."+meta.Name)
+		found := ""
+		for _, table := range meta.View.BaseTables {
+			if table == schema+"."+name {
+				return table
+			}
+			if strings.HasSuffix(table, "."+name) {
+				found = table
+			}
+		}
+		if found != "" {
+			return found
+		}
+		return schema + "." + name
+	}
+	lineage, err := parseViewLineage(meta.View.Definition, qualify, func(table string) []string {
+		return r.columns[table]
+	})
+	if err != nil {
+		return
+	}
+	meta.SetLineage(lineage)
+}
+
+// SQL のトークンの種類です。
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+// sqlToken は、SQL 文のトークンです。通常の識別子は大文字に変換します。
+type sqlToken struct {
+	kind   int
+	text   string
+	quoted bool
+}
+
+// tokenizeSQL は、SQL 文をトークンに分割します。コメントは読み飛ばします。
+func tokenizeSQL(sql string) ([]sqlToken, error) {
+	runes := []rune(sql)
+	tokens := []sqlToken{}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i += 2 + len([]rune(string(runes[i+2:])[:end])) + 2
+		case r == '\'' || r == '"':
+			str, n, ok := scanQuoted(runes[i:], r)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quote %c", r)
+			}
+			if r == '"' {
+				tokens = append(tokens, sqlToken{kind: tokenIdent, text: str, quoted: true})
+			} else {
+				tokens = append(tokens, sqlToken{kind: tokenString, text: str})
+			}
+			i += n
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' ||
+				runes[j] == 'E' || runes[j] == 'e') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+		case isIdentStart(r):
+			j := i
+			for j < len(runes) && (isIdentStart(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: tokenIdent, text: strings.ToUpper(string(runes[i:j]))})
+			i = j
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "||", "<=", ">=", "<>", "!=":
+					text = two
+				}
+			}
+			tokens = append(tokens, sqlToken{kind: tokenSymbol, text: text})
+			i += len([]rune(text))
+		}
+	}
+	return append(tokens, sqlToken{kind: tokenEOF}), nil
+}
+
+// scanQuoted は、quote で囲まれた文字列を読み、中身と読んだ文字数を返します。
+// quote の 2 回の繰り返しは quote 1 文字です。
+func scanQuoted(runes []rune, quote rune) (string, int, bool) {
+	buf := strings.Builder{}
+	for i := 1; i < len(runes); i++ {
+		if runes[i] != quote {
+			buf.WriteRune(runes[i])
+			continue
+		}
+		if i+1 < len(runes) && runes[i+1] == quote {
+			buf.WriteRune(quote)
+			i++
+			continue
+		}
+		return buf.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+// isIdentStart は、通常の識別子に使える文字かどうかを返します。
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '$' || r == '#' || r == '@'
+}
+
+// exprKeywords は、式の中でカラム名として扱わないキーワードです。
+var exprKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true, "LIKE": true,
+	"IN": true, "BETWEEN": true, "EXISTS": true, "ESCAPE": true, "DISTINCT": true,
+	"ALL": true, "ANY": true, "SOME": true, "TRUE": true, "FALSE": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "AS": true,
+	"CURRENT": true, "USER": true, "SESSION_USER": true, "SYSTEM_USER": true,
+	"CURRENT_DATE": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true,
+	"YEAR": true, "YEARS": true, "MONTH": true, "MONTHS": true, "DAY": true, "DAYS": true,
+	"HOUR": true, "HOURS": true, "MINUTE": true, "MINUTES": true,
+	"SECOND": true, "SECONDS": true, "MICROSECOND": true, "MICROSECONDS": true,
+	"OVER": true, "PARTITION": true, "BY": true, "ORDER": true, "ASC": true, "DESC": true,
+	"ROWS": true, "RANGE": true, "UNBOUNDED": true, "PRECEDING": true, "FOLLOWING": true,
+	"ROW": true, "NULLS": true, "FIRST": true, "LAST": true,
+}
+
+// tableStopKeywords は、FROM 句のテーブル参照の後で、相関名として扱わないキーワードです。
+var tableStopKeywords = map[string]bool{
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true,
+	"ON": true, "USING": true, "WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true,
+	"FETCH": true, "OFFSET": true, "LIMIT": true, "UNION": true, "EXCEPT": true,
+	"INTERSECT": true, "WITH": true, "FOR": true, "LATERAL": true,
+}
+
+// lineageColumn は、照会の出力カラムです。
+type lineageColumn struct {
+	name    string
+	sources []ColumnSource
+}
+
+// lineageRelation は、FROM 句のテーブル参照です。
+type lineageRelation struct {
+	// alias は、相関名です。なければ空です。
+	alias string
+	// table は、実表の正式名です。副照会などの場合は空です。
+	table string
+	// columns は、副照会の出力カラム、またはカラムが分かる実表のカラムです。
+	columns []lineageColumn
+	// known は、columns が分かっているかどうかです。
+	known bool
+}
+
+// qualifiedBy は、q で修飾されたカラムがこのテーブル参照のものかどうかを返します。
+func (r *lineageRelation) qualifiedBy(q string) bool {
+	if r.alias != "" {
+		return r.alias == q
+	}
+	if r.table == "" {
+		return false
+	}
+	return r.table == q || strings.HasSuffix(r.table, "."+q)
+}
+
+// column は、名前が一致するカラムの元のカラムを返します。
+func (r *lineageRelation) column(name string) ([]ColumnSource, bool) {
+	for _, c := range r.columns {
+		if c.name == name {
+			return c.sources, true
+		}
+	}
+	if r.table != "" && !r.known {
+		return []ColumnSource{{Table: r.table, Column: name}}, true
+	}
+	return nil, false
+}
+
+// lineageScope は、副照会ごとの FROM 句のテーブル参照です。
+type lineageScope struct {
+	relations []*lineageRelation
+	parent    *lineageScope
+}
+
+// lineageParser は、ビューの定義を解析します。
+type lineageParser struct {
+	tokens    []sqlToken
+	pos       int
+	qualify   func(name string) string
+	columnsOf func(table string) []string
+}
+
+func (p *lineageParser) peek(n int) sqlToken {
+	if p.pos+n < len(p.tokens) {
+		return p.tokens[p.pos+n]
+	}
+	return sqlToken{kind: tokenEOF}
+}
+
+// isKeyword は、n 個先のトークンが keyword かどうかを返します。
+func (p *lineageParser) isKeyword(n int, keyword string) bool {
+	t := p.peek(n)
+	return t.kind == tokenIdent && !t.quoted && t.text == keyword
+}
+
+func (p *lineageParser) isSymbol(n int, symbol string) bool {
+	t := p.peek(n)
+	return t.kind == tokenSymbol && t.text == symbol
+}
+
+func (p *lineageParser) expectSymbol(symbol string) error {
+	if !p.isSymbol(0, symbol) {
+		return fmt.Errorf("%q expected, but got %q", symbol, p.peek(0).text)
+	}
+	p.pos++
+	return nil
+}
+
+// skipParens は、( から対応する ) までを読み飛ばします。
+func (p *lineageParser) skipParens() error {
+	depth := 0
+	for {
+		t := p.peek(0)
+		switch {
+		case t.kind == tokenEOF:
+			return errors.New("unbalanced parentheses")
+		case t.kind == tokenSymbol && t.text == "(":
+			depth++
+		case t.kind == tokenSymbol && t.text == ")":
+			depth--
+		}
+		p.pos++
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// skipUntil は、括弧の外で stop が真になるトークンまで読み飛ばします。
+func (p *lineageParser) skipUntil(stop func() bool) error {
+	for {
+		t := p.peek(0)
+		if t.kind == tokenEOF || p.isSymbol(0, ")") || p.isSymbol(0, ";") || stop() {
+			return nil
+		}
+		if p.isSymbol(0, "(") {
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+			continue
+		}
+		p.pos++
+	}
+}
+
+// parseCreateView は、CREATE VIEW の AS までを読み、カラム名の指定を返します。
+func (p *lineageParser) parseCreateView() []string {
+	if !p.isKeyword(0, "CREATE") {
+		return nil
+	}
+	for !p.isKeyword(0, "VIEW") && p.peek(0).kind != tokenEOF {
+		p.pos++
+	}
+	p.pos++
+	p.parseName()
+	names := p.parseNameList()
+	if p.isKeyword(0, "AS") {
+		p.pos++
+	}
+	return names
+}
+
+// parseName は、ピリオドで区切られた名前を読みます。
+func (p *lineageParser) parseName() []string {
+	names := []string{}
+	for p.peek(0).kind == tokenIdent {
+		names = append(names, p.peek(0).text)
+		p.pos++
+		if !p.isSymbol(0, ".") || p.peek(1).kind != tokenIdent {
+			break
+		}
+		p.pos++
+	}
+	return names
+}
+
+// parseNameList は、( で始まる名前のリストを読みます。なければ nil を返します。
+func (p *lineageParser) parseNameList() []string {
+	if !p.isSymbol(0, "(") || p.peek(1).kind != tokenIdent {
+		return nil
+	}
+	p.pos++
+	names := []string{}
+	for p.peek(0).kind == tokenIdent {
+		names = append(names, p.peek(0).text)
+		p.pos++
+		if !p.isSymbol(0, ",") {
+			break
+		}
+		p.pos++
+	}
+	p.expectSymbol(")")
+	return names
+}
+
+// isQueryStart は、n 個先から照会が始まるかどうかを返します。
+func (p *lineageParser) isQueryStart(n int) bool {
+	for p.isSymbol(n, "(") {
+		n++
+	}
+	return p.isKeyword(n, "SELECT") || p.isKeyword(n, "WITH") || p.isKeyword(n, "VALUES")
+}
+
+// parseQuery は、共通表式、UNION などを含む照会を解析し、出力カラムを返します。
+func (p *lineageParser) parseQuery(outer *lineageScope,
+	ctes map[string][]lineageColumn) ([]lineageColumn, error) {
+
+	if p.isKeyword(0, "WITH") {
+		p.pos++
+		local := make(map[string][]lineageColumn, len(ctes))
+		for k, v := range ctes {
+			local[k] = v
+		}
+		for {
+			name := p.parseName()
+			if len(name) != 1 {
+				return nil, fmt.Errorf("common table expression name expected, but got %q", p.peek(0).text)
+			}
+			names := p.parseNameList()
+			if !p.isKeyword(0, "AS") {
+				return nil, fmt.Errorf("AS expected, but got %q", p.peek(0).text)
+			}
+			p.pos++
+			if err := p.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			columns, err := p.parseQuery(outer, local)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			local[name[0]] = renameColumns(columns, names)
+			if !p.isSymbol(0, ",") {
+				break
+			}
+			p.pos++
+		}
+		ctes = local
+	}
+
+	columns, err := p.parseQueryTerm(outer, ctes)
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(0, "UNION") || p.isKeyword(0, "EXCEPT") || p.isKeyword(0, "INTERSECT") {
+		p.pos++
+		if p.isKeyword(0, "ALL") || p.isKeyword(0, "DISTINCT") {
+			p.pos++
+		}
+		other, err := p.parseQueryTerm(outer, ctes)
+		if err != nil {
+			return nil, err
+		}
+		for i := range columns {
+			if i < len(other) {
+				columns[i].sources = appendSources(columns[i].sources, other[i].sources...)
+			}
+		}
+	}
+	return columns, p.skipUntil(func() bool { return false })
+}
+
+// parseQueryTerm は、SELECT 文、または括弧で囲まれた照会を解析します。
+func (p *lineageParser) parseQueryTerm(outer *lineageScope,
+	ctes map[string][]lineageColumn) ([]lineageColumn, error) {
+
+	switch {
+	case p.isSymbol(0, "("):
+		p.pos++
+		columns, err := p.parseQuery(outer, ctes)
+		if err != nil {
+			return nil, err
+		}
+		return columns, p.expectSymbol(")")
+	case p.isKeyword(0, "SELECT"):
+		return p.parseSelect(outer, ctes)
+	case p.isKeyword(0, "VALUES"):
+		// VALUES の値は、カラムに由来しません
+		return nil, p.skipUntil(func() bool {
+			return p.isKeyword(0, "UNION") || p.isKeyword(0, "EXCEPT") || p.isKeyword(0, "INTERSECT")
+		})
+	}
+	return nil, fmt.Errorf("SELECT expected, but got %q", p.peek(0).text)
+}
+
+// parseSelect は、SELECT 文を解析します。選択リストは FROM 句を解析してから解決します。
+func (p *lineageParser) parseSelect(outer *lineageScope,
+	ctes map[string][]lineageColumn) ([]lineageColumn, error) {
+
+	p.pos++
+	if p.isKeyword(0, "DISTINCT") || p.isKeyword(0, "ALL") {
+		p.pos++
+	}
+	items := [][2]int{}
+	start := p.pos
+	err := p.skipUntil(func() bool {
+		if p.isSymbol(0, ",") {
+			items = append(items, [2]int{start, p.pos})
+			start = p.pos + 1
+		}
+		return p.isKeyword(0, "FROM") || p.isKeyword(0, "UNION") ||
+			p.isKeyword(0, "EXCEPT") || p.isKeyword(0, "INTERSECT")
+	})
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, [2]int{start, p.pos})
+
+	scope := &lineageScope{parent: outer}
+	if p.isKeyword(0, "FROM") {
+		p.pos++
+		if err := p.parseFrom(scope, ctes); err != nil {
+			return nil, err
+		}
+	}
+	end := p.pos
+	err = p.skipUntil(func() bool {
+		return p.isKeyword(0, "UNION") || p.isKeyword(0, "EXCEPT") || p.isKeyword(0, "INTERSECT")
+	})
+	if err != nil {
+		return nil, err
+	}
+	end, p.pos = p.pos, end
+
+	columns := []lineageColumn{}
+	for _, item := range items {
+		list, err := p.selectItem(item[0], item[1], scope, ctes)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, list...)
+	}
+	p.pos = end
+	return columns, nil
+}
+
+// selectItem は、選択リストの 1 項目を解決します。* は複数のカラムになります。
+func (p *lineageParser) selectItem(start int, end int, scope *lineageScope,
+	ctes map[string][]lineageColumn) ([]lineageColumn, error) {
+
+	tokens := p.tokens[start:end]
+	n := len(tokens)
+	if n > 0 && tokens[n-1].kind == tokenSymbol && tokens[n-1].text == "*" {
+		qualifier := []string{}
+		for i := 0; i+1 < n; i += 2 {
+			qualifier = append(qualifier, tokens[i].text)
+		}
+		return expandStar(scope, strings.Join(qualifier, ".")), nil
+	}
+
+	name := ""
+	if n >= 2 && tokens[n-1].kind == tokenIdent && !isExprKeyword(tokens[n-1]) {
+		prev := tokens[n-2]
+		switch {
+		case prev.kind == tokenIdent && !prev.quoted && prev.text == "AS":
+			name, end = tokens[n-1].text, end-2
+		case prev.kind == tokenIdent && (prev.quoted || !exprKeywords[prev.text] || prev.text == "END"),
+			prev.kind == tokenString, prev.kind == tokenNumber,
+			prev.kind == tokenSymbol && prev.text == ")":
+			name, end = tokens[n-1].text, end-1
+		}
+	}
+	if name == "" && end-start >= 1 && p.tokens[end-1].kind == tokenIdent &&
+		(end-start == 1 || end-start >= 3 && p.tokens[end-2].text == "." && isSimpleName(p.tokens[start:end])) {
+		name = p.tokens[end-1].text
+	}
+
+	sources, err := p.expression(start, end, scope, ctes)
+	if err != nil {
+		return nil, err
+	}
+	return []lineageColumn{{name: name, sources: sources}}, nil
+}
+
+// isSimpleName は、トークンがピリオドで区切られた名前だけかどうかを返します。
+func isSimpleName(tokens []sqlToken) bool {
+	for i, t := range tokens {
+		if i%2 == 0 && t.kind != tokenIdent || i%2 == 1 && t.text != "." {
+			return false
+		}
+	}
+	return true
+}
+
+// isExprKeyword は、式の中のキーワードかどうかを返します。
+func isExprKeyword(t sqlToken) bool {
+	return t.kind == tokenIdent && !t.quoted && exprKeywords[t.text]
+}
+
+// expression は、start から end までの式が参照するカラムの元のカラムを返します。
+func (p *lineageParser) expression(start int, end int, scope *lineageScope,
+	ctes map[string][]lineageColumn) ([]ColumnSource, error) {
+
+	sources := []ColumnSource{}
+	saved := p.pos
+	defer func() { p.pos = saved }()
+	for p.pos = start; p.pos < end; {
+		t := p.peek(0)
+		switch {
+		case t.kind == tokenSymbol && t.text == "(" && p.isQueryStart(1):
+			p.pos++
+			columns, err := p.parseQuery(scope, ctes)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range columns {
+				sources = appendSources(sources, c.sources...)
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+		case t.kind == tokenIdent && !t.quoted && t.text == "CURRENT":
+			// CURRENT DATE などの特殊レジスター
+			p.pos += 2
+		case t.kind == tokenIdent && !t.quoted && t.text == "AS":
+			// CAST(x AS type) の型
+			p.pos++
+			p.parseName()
+			if p.isSymbol(0, "(") {
+				if err := p.skipParens(); err != nil {
+					return nil, err
+				}
+			}
+		case t.kind == tokenIdent:
+			name := p.parseName()
+			if p.isSymbol(0, "(") || len(name) == 1 && isExprKeyword(t) {
+				// 関数名、キーワード
+				continue
+			}
+			sources = appendSources(sources, resolveColumn(scope, name)...)
+		default:
+			p.pos++
+		}
+	}
+	return sources, nil
+}
+
+// parseFrom は、FROM 句のテーブル参照を scope に追加します。
+func (p *lineageParser) parseFrom(scope *lineageScope, ctes map[string][]lineageColumn) error {
+	for {
+		if err := p.parseTableRef(scope, ctes); err != nil {
+			return err
+		}
+		if !p.isSymbol(0, ",") {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+// parseTableRef は、結合を含むテーブル参照を解析します。
+func (p *lineageParser) parseTableRef(scope *lineageScope, ctes map[string][]lineageColumn) error {
+	if err := p.parseTablePrimary(scope, ctes); err != nil {
+		return err
+	}
+	for {
+		n := 0
+		switch {
+		case p.isKeyword(0, "JOIN"):
+		case p.isKeyword(0, "INNER") || p.isKeyword(0, "CROSS"):
+			n = 1
+		case p.isKeyword(0, "LEFT") || p.isKeyword(0, "RIGHT") || p.isKeyword(0, "FULL"):
+			n = 1
+			if p.isKeyword(1, "OUTER") {
+				n = 2
+			}
+		default:
+			return nil
+		}
+		if !p.isKeyword(n, "JOIN") {
+			return nil
+		}
+		p.pos += n + 1
+		if err := p.parseTablePrimary(scope, ctes); err != nil {
+			return err
+		}
+		switch {
+		case p.isKeyword(0, "ON"):
+			p.pos++
+			err := p.skipUntil(func() bool {
+				t := p.peek(0)
+				if p.isSymbol(0, ",") {
+					return true
+				}
+				if t.kind != tokenIdent || t.quoted || !tableStopKeywords[t.text] {
+					return false
+				}
+				// LEFT(...) などの関数は結合ではありません
+				return !p.isSymbol(1, "(")
+			})
+			if err != nil {
+				return err
+			}
+		case p.isKeyword(0, "USING"):
+			p.pos++
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseTablePrimary は、テーブル名、副照会、括弧で囲まれた結合を解析します。
+func (p *lineageParser) parseTablePrimary(scope *lineageScope, ctes map[string][]lineageColumn) error {
+	relation := &lineageRelation{}
+	switch {
+	case p.isKeyword(0, "LATERAL") || p.isKeyword(0, "TABLE"):
+		p.pos++
+		if !p.isQueryStart(1) {
+			// 表関数の結果は、カラムに由来しません
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+			relation.known = true
+			break
+		}
+		fallthrough
+	case p.isSymbol(0, "(") && p.isQueryStart(1):
+		p.pos++
+		columns, err := p.parseQuery(scope, ctes)
+		if err != nil {
+			return err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return err
+		}
+		relation.columns, relation.known = columns, true
+	case p.isSymbol(0, "("):
+		p.pos++
+		if err := p.parseTableRef(scope, ctes); err != nil {
+			return err
+		}
+		return p.expectSymbol(")")
+	case p.peek(0).kind == tokenIdent:
+		name := p.parseName()
+		if columns, ok := ctes[name[0]]; ok && len(name) == 1 {
+			relation.columns, relation.known = columns, true
+			break
+		}
+		relation.table = strings.Join(name, ".")
+		if len(name) == 1 {
+			relation.table = p.qualify(name[0])
+		}
+		if p.columnsOf != nil {
+			if names := p.columnsOf(relation.table); names != nil {
+				for _, c := range names {
+					relation.columns = append(relation.columns, lineageColumn{
+						name: c, sources: []ColumnSource{{Table: relation.table, Column: c}},
+					})
+				}
+				relation.known = true
+			}
+		}
+	default:
+		return fmt.Errorf("table reference expected, but got %q", p.peek(0).text)
+	}
+
+	if p.isKeyword(0, "AS") {
+		p.pos++
+	}
+	if t := p.peek(0); t.kind == tokenIdent && (t.quoted || !tableStopKeywords[t.text]) {
+		relation.alias = t.text
+		p.pos++
+		if names := p.parseNameList(); names != nil && relation.known {
+			relation.columns = renameColumns(relation.columns, names)
+		}
+	}
+	scope.relations = append(scope.relations, relation)
+	return nil
+}
+
+// renameColumns は、出力カラムの名前を names に置き換えます。
+func renameColumns(columns []lineageColumn, names []string) []lineageColumn {
+	if names == nil {
+		return columns
+	}
+	result := make([]lineageColumn, len(columns))
+	for i, c := range columns {
+		result[i] = c
+		if i < len(names) {
+			result[i].name = names[i]
+		}
+	}
+	return result
+}
+
+// resolveColumn は、修飾を含むカラム名の元のカラムを、内側の副照会から順に探します。
+func resolveColumn(scope *lineageScope, name []string) []ColumnSource {
+	column := name[len(name)-1]
+	qualifier := strings.Join(name[:len(name)-1], ".")
+	for s := scope; s != nil; s = s.parent {
+		if qualifier != "" {
+			for _, r := range s.relations {
+				if r.qualifiedBy(qualifier) {
+					sources, _ := r.column(column)
+					return sources
+				}
+			}
+			continue
+		}
+
+		var found []ColumnSource
+		count := 0
+		for _, r := range s.relations {
+			if !r.known {
+				continue
+			}
+			if sources, ok := r.column(column); ok {
+				found = sources
+				count++
+			}
+		}
+		if count == 1 {
+			return found
+		}
+		if count == 0 && len(s.relations) == 1 && !s.relations[0].known {
+			sources, _ := s.relations[0].column(column)
+			return sources
+		}
+		if count > 1 || len(s.relations) > 0 && !allKnown(s.relations) {
+			// あいまい、またはカラムが分からないテーブルがあります
+			return nil
+		}
+	}
+	return nil
+}
+
+// allKnown は、すべてのテーブル参照のカラムが分かっているかどうかを返します。
+func allKnown(relations []*lineageRelation) bool {
+	for _, r := range relations {
+		if !r.known {
+			return false
+		}
+	}
+	return true
+}
+
+// expandStar は、* または 修飾子.* を出力カラムに展開します。
+// カラムが分からないテーブル参照は展開できません。
+func expandStar(scope *lineageScope, qualifier string) []lineageColumn {
+	columns := []lineageColumn{}
+	for _, r := range scope.relations {
+		if qualifier == "" || r.qualifiedBy(qualifier) {
+			columns = append(columns, r.columns...)
+		}
+	}
+	return columns
+}
+
+// appendSources は、重複を除いて元のカラムを追加します。
+func appendSources(sources []ColumnSource, list ...ColumnSource) []ColumnSource {
+	for _, s := range list {
+		found := false
+		for _, t := range sources {
+			if s == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
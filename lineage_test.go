@@ -0,0 +1,154 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseViewLineage(t *testing.T) {
+	catalog := map[string][]string{
+		"S.EMPLOYEE":   {"EMPNO", "FIRSTNME", "LASTNAME", "WORKDEPT", "SALARY"},
+		"S.DEPARTMENT": {"DEPTNO", "DEPTNAME", "MGRNO"},
+		"S.PROJECT":    {"PROJNO", "DEPTNO"},
+	}
+	columnsOf := func(table string) []string {
+		return catalog[table]
+	}
+	// src は、"テーブル.カラム" の一覧を ColumnSource にします。
+	src := func(names ...string) []ColumnSource {
+		list := []ColumnSource{}
+		for _, n := range names {
+			i := strings.LastIndex(n, ".")
+			list = append(list, ColumnSource{Table: n[:i], Column: n[i+1:]})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name string
+		sql  string
+		want []ColumnLineage
+	}{
+		{"alias and join",
+			`CREATE VIEW S.V AS SELECT E.EMPNO, E.FIRSTNME || ' ' || E.LASTNAME AS NAME, D.DEPTNAME
+			   FROM S.EMPLOYEE E LEFT OUTER JOIN S.DEPARTMENT D ON E.WORKDEPT = D.DEPTNO`,
+			[]ColumnLineage{
+				{"EMPNO", src("S.EMPLOYEE.EMPNO")},
+				{"NAME", src("S.EMPLOYEE.FIRSTNME", "S.EMPLOYEE.LASTNAME")},
+				{"DEPTNAME", src("S.DEPARTMENT.DEPTNAME")},
+			}},
+		{"view column list and default schema",
+			`CREATE VIEW V (ID, DEPT) AS SELECT EMPNO, DEPTNAME FROM EMPLOYEE, DEPARTMENT WHERE WORKDEPT = DEPTNO`,
+			[]ColumnLineage{
+				{"ID", src("S.EMPLOYEE.EMPNO")},
+				{"DEPT", src("S.DEPARTMENT.DEPTNAME")},
+			}},
+		{"case and functions",
+			`SELECT CASE WHEN SALARY > 1000 THEN 'HIGH' ELSE UPPER(LASTNAME) END GRADE,
+			        COALESCE(CAST(SALARY AS DECIMAL(9, 2)), 0) * 12 AS ANNUAL,
+			        CURRENT DATE AS TODAY, COUNT(*) CNT
+			   FROM S.EMPLOYEE -- comment
+			  GROUP BY SALARY, LASTNAME`,
+			[]ColumnLineage{
+				{"GRADE", src("S.EMPLOYEE.SALARY", "S.EMPLOYEE.LASTNAME")},
+				{"ANNUAL", src("S.EMPLOYEE.SALARY")},
+				{"TODAY", src()},
+				{"CNT", src()},
+			}},
+		{"common table expression and union",
+			`WITH T (NO, DEPT) AS (SELECT EMPNO, WORKDEPT FROM S.EMPLOYEE)
+			 SELECT NO, DEPT FROM T
+			 UNION ALL
+			 SELECT PROJNO, DEPTNO FROM S.PROJECT`,
+			[]ColumnLineage{
+				{"NO", src("S.EMPLOYEE.EMPNO", "S.PROJECT.PROJNO")},
+				{"DEPT", src("S.EMPLOYEE.WORKDEPT", "S.PROJECT.DEPTNO")},
+			}},
+		{"star and derived table",
+			`SELECT D.*, X.TOTAL FROM S.DEPARTMENT D
+			   JOIN (SELECT WORKDEPT, SUM(SALARY) FROM S.EMPLOYEE GROUP BY WORKDEPT) AS X (DEPT, TOTAL)
+			     ON X.DEPT = D.DEPTNO`,
+			[]ColumnLineage{
+				{"DEPTNO", src("S.DEPARTMENT.DEPTNO")},
+				{"DEPTNAME", src("S.DEPARTMENT.DEPTNAME")},
+				{"MGRNO", src("S.DEPARTMENT.MGRNO")},
+				{"TOTAL", src("S.EMPLOYEE.SALARY")},
+			}},
+		{"scalar subquery and unknown table",
+			`SELECT P.PROJNO, (SELECT DEPTNAME FROM S.DEPARTMENT WHERE DEPTNO = P.DEPTNO) AS DEPTNAME,
+			        "Mixed" FROM S.PROJECT P, S.UNKNOWN`,
+			[]ColumnLineage{
+				{"PROJNO", src("S.PROJECT.PROJNO")},
+				{"DEPTNAME", src("S.DEPARTMENT.DEPTNAME")},
+				{"Mixed", src()},
+			}},
+		{"unknown single table",
+			`SELECT A, B + 1 AS C FROM OTHER.T`,
+			[]ColumnLineage{
+				{"A", src("OTHER.T.A")},
+				{"C", src("OTHER.T.B")},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseViewLineage(tt.sql, "S", columnsOf)
+			if err != nil {
+				t.Fatalf("ParseViewLineage() error :%s", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseViewLineage() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || len(got[i].Sources)+len(tt.want[i].Sources) > 0 &&
+					!reflect.DeepEqual(got[i].Sources, tt.want[i].Sources) {
+					t.Errorf("ParseViewLineage()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseViewLineageError(t *testing.T) {
+	for _, sql := range []string{
+		`SELECT 'abc FROM T`,
+		`SELECT A FROM (SELECT A FROM T`,
+		`DELETE FROM T`,
+	} {
+		if _, err := ParseViewLineage(sql, "S", nil); err == nil {
+			t.Errorf("ParseViewLineage(%q) error is nil", sql)
+		}
+	}
+}
+
+func TestLineageResolverQualifier(t *testing.T) {
+	r := newLineageResolver()
+	for _, table := range []string{"B.EMP", "C.DEPT"} {
+		r.addColumn(table, "ID")
+	}
+	tests := []struct {
+		name       string
+		qualifier  string
+		baseTables []string
+		want       string
+	}{
+		// ビューのスキーマ A ではなく、作成したときの既定のスキーマ B で解決します
+		{"qualifier", "B", []string{"B.EMP"}, "B.EMP"},
+		// 既定のスキーマがカタログにない場合は、参照するテーブルで解決します
+		{"base table", "", []string{"C.DEPT", "B.EMP"}, "B.EMP"},
+		{"view schema", "", nil, "A.EMP"},
+	}
+	for _, tt := range tests {
+		meta := &Metadata{Name: "V", FormalName: "A.V", Columns: []Column{{Name: "ID"}}}
+		meta.SetView("CREATE VIEW A.V AS SELECT ID FROM EMP", tt.qualifier, tt.baseTables)
+		r.Apply(meta)
+		want := []ColumnSource{{Table: tt.want, Column: "ID"}}
+		if got := meta.Columns[0].Lineage; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Lineage = %v, want %v", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LevelTrace は、カタログの SQL 文を 1 つずつ記録するログレベルです。
+const LevelTrace = slog.LevelDebug - 4
+
+// logLevels は、Config.LogLevel に指定できる値です。
+var logLevels = map[string]slog.Level{
+	"trace": LevelTrace,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// logFormats は、Config.LogFormat に指定できる値と Handler の作り方です。
+var logFormats = map[string]func(w io.Writer, opts *slog.HandlerOptions) slog.Handler{
+	"text": func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return slog.NewTextHandler(w, opts) },
+	"json": func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return slog.NewJSONHandler(w, opts) },
+}
+
+// logNames は、m のキーをソートして返します。
+func logNames[T any](m map[string]T) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// NewLogger は、Config.LogFormat の形式で Config.LogLevel 以上のログを w に書く Logger を作ります。
+// 空の場合は text 形式、info レベルです。文字列とエラーの値は Config.Redact と同様に
+// パスワードを伏せます。
+func NewLogger(w io.Writer, config *Config) (*slog.Logger, error) {
+	level, ok := logLevels[strings.ToLower(config.LogLevel)]
+	if config.LogLevel == "" {
+		level, ok = slog.LevelInfo, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("logLevel %q is not one of %s",
+			config.LogLevel, strings.Join(logNames(logLevels), ", "))
+	}
+	newHandler, ok := logFormats[strings.ToLower(config.LogFormat)]
+	if config.LogFormat == "" {
+		newHandler, ok = logFormats["text"], true
+	}
+	if !ok {
+		return nil, fmt.Errorf("logFormat %q is not one of %s",
+			config.LogFormat, strings.Join(logNames(logFormats), ", "))
+	}
+	return slog.New(newHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch v := a.Value.Any().(type) {
+			case slog.Level:
+				if a.Key == slog.LevelKey && v == LevelTrace {
+					a.Value = slog.StringValue("TRACE")
+				}
+			case string:
+				a.Value = slog.StringValue(config.redact(v))
+			case error:
+				a.Value = slog.StringValue(config.Redact(v))
+			}
+			return a
+		},
+	})), nil
+}
+
+// queryTrace は、SQL 文の実行を LevelTrace で記録します。送る時点で "query started"、
+// 結果を読み終えた時点で "query" を記録し、最初の行を読むまでの時間と、
+// 読み終えるまでの時間を分けて記録します。
+type queryTrace struct {
+	ctx      context.Context
+	stmt     string
+	enabled  bool
+	start    time.Time
+	firstRow time.Duration
+}
+
+// startQuery は、SQL 文 stmt を送る直前に呼び、"query started" を記録します。
+func startQuery(ctx context.Context, stmt string) *queryTrace {
+	t := &queryTrace{ctx: ctx, stmt: stmt, start: time.Now()}
+	t.enabled = slog.Default().Enabled(ctx, LevelTrace)
+	if t.enabled {
+		slog.Default().LogAttrs(ctx, LevelTrace, "query started", slog.String("sql", t.sql()))
+	}
+	return t
+}
+
+// sql は、空白をまとめた SQL 文を返します。
+func (t *queryTrace) sql() string {
+	return strings.Join(strings.Fields(t.stmt), " ")
+}
+
+// row は、結果の行を読んだことを記録します。最初の行までの時間だけを保持します。
+func (t *queryTrace) row() {
+	if t.firstRow == 0 {
+		t.firstRow = time.Since(t.start)
+	}
+}
+
+// done は、"query" を記録します。rows は結果の行数で、不明な場合は -1 です。
+func (t *queryTrace) done(rows int, err error) {
+	if !t.enabled {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("sql", t.sql()),
+		slog.Duration("duration", time.Since(t.start)),
+	}
+	if t.firstRow > 0 {
+		attrs = append(attrs, slog.Duration("firstRow", t.firstRow))
+	}
+	if rows >= 0 {
+		attrs = append(attrs, slog.Int("rows", rows))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	slog.Default().LogAttrs(t.ctx, LevelTrace, "query", attrs...)
+}
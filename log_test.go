@@ -0,0 +1,81 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	for _, config := range []*Config{{LogLevel: "verbose"}, {LogFormat: "xml"}} {
+		if _, err := NewLogger(&bytes.Buffer{}, config); err == nil {
+			t.Errorf("NewLogger(%+v) error = nil", config)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	logger, err := NewLogger(out, &Config{LogLevel: "TRACE", LogFormat: "json", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewLogger() error :%s", err)
+	}
+	logger.Log(context.Background(), LevelTrace, "connect",
+		"dsn", "HOSTNAME=db;PWD=secret;", "error", errors.New("login secret failed"))
+	for _, want := range []string{`"level":"TRACE"`, `"dsn":"HOSTNAME=db;PWD=****;"`, `"error":"login **** failed"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("log = %s, want %s", out, want)
+		}
+	}
+
+	out.Reset()
+	logger, _ = NewLogger(out, &Config{})
+	logger.Debug("hidden")
+	logger.Info("shown")
+	if got := out.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "level=INFO msg=shown") {
+		t.Errorf("log = %s, want only info", got)
+	}
+}
+
+func TestTraceQuery(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger, _ := NewLogger(out, &Config{LogLevel: "trace"})
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	config := testConfig()
+	config.TargetSchema = []string{"DB2INST1"}
+	runExtractor(t, config)
+
+	for _, want := range []string{
+		`level=TRACE msg="query started" sql="SELECT COLNAME FROM SYSCAT.COLUMNS WHERE TABSCHEMA='SYSCAT' AND TABNAME='TABLES' ORDER BY COLNO"` + "\n",
+		`level=TRACE msg=query sql="SELECT COLNAME FROM SYSCAT.COLUMNS WHERE TABSCHEMA='SYSCAT' AND TABNAME='TABLES' ORDER BY COLNO" duration=`,
+		`FROM SYSCAT.TABLES WHERE TYPE in ('S', 'T', 'U', 'V', 'W') AND TABSCHEMA in ('DB2INST1') ORDER BY TABSCHEMA, TABNAME" duration=`,
+		`level=INFO msg="schema extracted" schema=DB2INST1 objects=`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("log lacks %s:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out.String(), "ORDER BY TABSCHEMA, TABNAME\" duration=") ||
+		!strings.Contains(out.String(), " firstRow=") || !strings.Contains(out.String(), " rows=3") {
+		t.Errorf("log lacks the first row time and row count of tables:\n%s", out)
+	}
+	// 送った時点の記録は、結果を読み終えた時点の記録より先です
+	started := strings.Index(out.String(), `msg="query started" sql="SELECT TABSCHEMA,TABNAME,OWNER,`)
+	finished := strings.Index(out.String(), `msg=query sql="SELECT TABSCHEMA,TABNAME,OWNER,`)
+	if started < 0 || finished < started {
+		t.Errorf("query started at %d, finished at %d in log:\n%s", started, finished, out)
+	}
+
+	out.Reset()
+	startQuery(context.Background(), "SELECT 1").done(-1, nil)
+	if got := out.String(); strings.Contains(got, "rows=") || strings.Contains(got, "firstRow=") {
+		t.Errorf("queryTrace.done() = %s, want no rows", got)
+	}
+}
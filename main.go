@@ -6,9 +6,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	_ "github.com/ibmdb/go_ibm_db"
 )
@@ -16,51 +20,344 @@ import (
 // Db2Driver は、DB2 のドライバー名です。
 const Db2Driver = "go_ibm_db"
 
+// 終了コード
+const (
+	exitOK             = 0
+	exitReadConfig     = -1
+	exitParseConfig    = -2
+	exitFindSchema     = -3
+	exitWriteConfig    = -4
+	exitCreateOutput   = -5
+	exitInvalidConfig  = -6
+	exitUsage          = -7
+	exitDetectPlatform = -8
+	exitExtract        = -9
+)
+
+const usage = `Usage: mashu-csv-db2 [flags] [command] [flags]
+
+Commands:
+  extract          extract metadata into the output file
+  schemas          find schemas and write them to targetSchema of the config file
+                   (or to the output file when -o is given)
+  validate-config  validate the config file
+  list-extractors  list registered extractors and output formats
+
+Without a command, "schemas" runs when targetSchema is empty, otherwise "extract".
+
+The password is never written back to the config file. It can be given by
+the secretsFile, the ` + EnvPassword + ` environment variable, or a prompt.
+
+Flags:
+`
+
 func main() {
-	ctx := context.Background()
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	data, err := os.ReadFile("config.json")
-	if err != nil {
-		fmt.Printf("config.json read error (%#v)\n", err)
-		os.Exit(-1)
+// options は、コマンドラインで指定された設定の上書きです。
+type options struct {
+	config       string
+	output       string
+	systemSchema string
+	targetSchema string
+	format       string
+	secretsFile  string
+	onError      string
+	parallelism  int
+	progress     bool
+	logLevel     string
+	logFormat    string
+}
+
+// apply は、指定されたオプションで Config を上書きします。
+func (o *options) apply(config *Config) {
+	if o.output != "" {
+		config.CSVFile = o.output
 	}
+	if o.systemSchema != "" {
+		config.SystemSchema = o.systemSchema
+	}
+	if o.targetSchema != "" {
+		config.TargetSchema = nil
+		for _, s := range strings.Split(o.targetSchema, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				config.TargetSchema = append(config.TargetSchema, s)
+			}
+		}
+	}
+	if o.format != "" {
+		config.Format = o.format
+	}
+	if o.secretsFile != "" {
+		config.SecretsFile = o.secretsFile
+	}
+	if o.onError != "" {
+		config.OnError = o.onError
+	}
+	if o.parallelism != 0 {
+		config.Parallelism = o.parallelism
+	}
+	if o.progress {
+		config.Progress = true
+	}
+	if o.logLevel != "" {
+		config.LogLevel = o.logLevel
+	}
+	if o.logFormat != "" {
+		config.LogFormat = o.logFormat
+	}
+}
 
-	var config Config
-	err = json.Unmarshal(data, &config)
+// newFlagSet は、全コマンド共通のフラグを定義した FlagSet を作ります。
+func newFlagSet(stderr io.Writer) (*flag.FlagSet, *options) {
+	opts := &options{}
+	fs := flag.NewFlagSet("mashu-csv-db2", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.config, "config", "config.json", "config file path")
+	fs.StringVar(&opts.output, "o", "", "output file path, - for stdout (overrides csvfile)")
+	fs.StringVar(&opts.systemSchema, "system-schema", "",
+		"system schema, SYSCAT, QSYS2, SYSIBM or auto (overrides systemSchema)")
+	fs.StringVar(&opts.targetSchema, "schema", "",
+		"comma separated target schemas (overrides targetSchema)")
+	fs.StringVar(&opts.format, "format", "",
+		"output format, "+strings.Join(WriterNames(), ", ")+" (overrides format)")
+	fs.StringVar(&opts.secretsFile, "secrets", "",
+		"secrets file path with hostname, userid and password (overrides secretsFile)")
+	fs.StringVar(&opts.onError, "on-error", "",
+		"error policy, fail, skip-table or keep-going (overrides onError)")
+	fs.IntVar(&opts.parallelism, "parallel", 0,
+		"number of schemas extracted concurrently (overrides parallelism)")
+	fs.BoolVar(&opts.progress, "progress", false,
+		"report progress to stderr (overrides progress)")
+	fs.StringVar(&opts.logLevel, "log-level", "",
+		"log level, trace (with catalog SQL), debug, info, warn or error (overrides logLevel)")
+	fs.StringVar(&opts.logFormat, "log-format", "",
+		"log format, text or json (overrides logFormat)")
+	return fs, opts
+}
+
+// run は、コマンドラインを解釈してコマンドを実行し、終了コードを返します。
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, opts := newFlagSet(stderr)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	command := ""
+	if fs.NArg() > 0 {
+		// コマンドの後ろに書かれたフラグも受け付けます
+		command = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return exitUsage
+		}
+		if fs.NArg() > 0 {
+			fmt.Fprintf(stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+			return exitUsage
+		}
+	}
+
+	if command == "list-extractors" {
+		return listExtractors(stdout)
+	}
+
+	config, err := LoadConfig(opts.config)
 	if err != nil {
-		fmt.Printf("config.json unmarshal error (%#v)\n", err)
-		os.Exit(-2)
+		fmt.Fprintf(stderr, "config read error (%v)\n", err)
+		if os.IsNotExist(err) {
+			return exitReadConfig
+		}
+		return exitParseConfig
 	}
+	opts.apply(config)
 
-	extractor := GetExtractor(Db2Driver + "." + config.SystemSchema)
-	extractor.SetConfig(&config)
+	if command == "" {
+		command = "extract"
+		if len(config.TargetSchema) == 0 {
+			command = "schemas"
+		}
+	}
 
-	if len(config.TargetSchema) == 0 {
-		list, err := extractor.FindSchema(ctx, config.Db2DSN())
-		if err != nil {
-			fmt.Printf("FindSchema error (%#v)\n", err)
-			os.Exit(-3)
+	var prompt func() (string, error)
+	if command != "validate-config" && isTerminal(os.Stdin) {
+		prompt = func() (string, error) {
+			fmt.Fprint(stderr, "Password: ")
+			defer fmt.Fprintln(stderr)
+			return readPassword(os.Stdin)
 		}
-		config.TargetSchema = list
-		b, err := json.MarshalIndent(config, "", "  ")
+	}
+	err = config.ResolveSecrets(prompt)
+	if err != nil {
+		fmt.Fprintf(stderr, "secrets read error (%v)\n", err)
+		return exitReadConfig
+	}
+
+	// validate-config は、ログの設定の誤りも検証結果として表示します
+	if command != "validate-config" {
+		logger, err := NewLogger(stderr, config)
 		if err != nil {
-			fmt.Printf("config.jcon marshal error (%#v)\n", err)
-			os.Exit(-4)
+			fmt.Fprintf(stderr, "invalid config:\n%v\n", err)
+			return exitInvalidConfig
 		}
-		os.WriteFile("config.json", b, 0666)
-		fmt.Print("add targetSchema to config.json ;)\n")
-	} else {
-		output, err := os.Create(config.CSVFile)
-		if err != nil {
-			fmt.Printf("csvfile create error (%#v)\n", err)
-			os.Exit(-5)
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(logger)
+	}
+
+	switch command {
+	case "validate-config":
+		return validateConfig(config, stdout, stderr)
+	case "schemas":
+		return findSchema(ctx, config, opts, stdout, stderr)
+	case "extract":
+		return extract(ctx, config, stdout, stderr)
+	}
+	fmt.Fprintf(stderr, "unknown command: %s\n", command)
+	fs.Usage()
+	return exitUsage
+}
+
+// resolveExtractor は、systemSchema に対応する MetadataExtractor を返します。
+// 自動判定した場合は、判定結果を表示します。
+func resolveExtractor(ctx context.Context,
+	config *Config, stderr io.Writer) (MetadataExtractor, int) {
+
+	extractor, systemSchema, err := ResolveExtractor(ctx, config)
+	if err != nil {
+		fmt.Fprintf(stderr, "extractor error (%s)\n", config.Redact(err))
+		if strings.EqualFold(config.SystemSchema, AutoSystemSchema) {
+			return nil, exitDetectPlatform
 		}
-		defer output.Close()
+		return nil, exitInvalidConfig
+	}
+	if systemSchema != config.SystemSchema {
+		fmt.Fprintf(stderr, "detected %s, using %s extractor\n",
+			PlatformName(systemSchema), systemSchema)
+	}
+	return extractor, exitOK
+}
+
+// listExtractors は、登録されている MetadataExtractor と出力形式を表示します。
+func listExtractors(stdout io.Writer) int {
+	fmt.Fprintln(stdout, "extractors:")
+	for _, name := range ExtractorNames() {
+		systemSchema := strings.TrimPrefix(name, Db2Driver+".")
+		fmt.Fprintf(stdout, "  %-8s %s\n", systemSchema, PlatformName(systemSchema))
+	}
+	fmt.Fprintf(stdout, "  %-8s detect from the connected server\n", AutoSystemSchema)
+	fmt.Fprintln(stdout, "formats:")
+	for _, name := range WriterNames() {
+		fmt.Fprintf(stdout, "  %s\n", name)
+	}
+	return exitOK
+}
+
+// validateConfig は、設定を検証して結果を表示します。
+func validateConfig(config *Config, stdout, stderr io.Writer) int {
+	err := config.Validate()
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid config:\n%v\n", err)
+		return exitInvalidConfig
+	}
+	fmt.Fprintln(stdout, "config is valid")
+	return exitOK
+}
+
+// findSchema は、スキーマの一覧を設定ファイルか出力ファイルに書き込みます。
+func findSchema(ctx context.Context, config *Config, opts *options,
+	stdout, stderr io.Writer) int {
+
+	extractor, code := resolveExtractor(ctx, config, stderr)
+	if extractor == nil {
+		return code
+	}
+
+	list, err := extractor.FindSchema(ctx, config.Db2DSN())
+	if err != nil {
+		fmt.Fprintf(stderr, "FindSchema error (%s)\n", config.Redact(err))
+		return exitFindSchema
+	}
 
-		err = extractor.Run(ctx, config.Db2DSN(), output)
+	if opts.output != "" {
+		output, closer, err := openOutput(opts.output, stdout)
 		if err != nil {
-			fmt.Printf("Run error (%#v)\n", err)
+			fmt.Fprintf(stderr, "output create error (%v)\n", err)
+			return exitCreateOutput
 		}
-		fmt.Printf("Let's import %s into Mashu (^^)b\n", config.CSVFile)
+		defer closer()
+		for _, s := range list {
+			fmt.Fprintln(output, s)
+		}
+		return exitOK
+	}
+
+	config.TargetSchema = list
+	err = config.Save(opts.config)
+	if err != nil {
+		fmt.Fprintf(stderr, "config write error (%v)\n", err)
+		return exitWriteConfig
+	}
+	fmt.Fprintf(stderr, "add targetSchema to %s ;)\n", opts.config)
+	return exitOK
+}
+
+// extract は、メタデータを抽出して出力ファイルに書き込みます。
+func extract(ctx context.Context, config *Config, stdout, stderr io.Writer) int {
+	extractor, code := resolveExtractor(ctx, config, stderr)
+	if extractor == nil {
+		return code
+	}
+
+	output, closer, err := openOutput(config.CSVFile, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "output create error (%v)\n", err)
+		return exitCreateOutput
+	}
+	defer closer()
+
+	config.WarningHandler = func(w Warning) {
+		slog.WarnContext(ctx, "warning", "stage", w.Stage, "object", w.Object, "message", w.Message)
+	}
+	stopProgress := func() {}
+	if config.Progress {
+		config.ProgressCounter = NewProgress()
+		reportCtx, stop := context.WithCancel(ctx)
+		reported := make(chan struct{})
+		go func() {
+			defer close(reported)
+			f, ok := stderr.(*os.File)
+			config.ProgressCounter.Report(reportCtx, stderr, ok && isTerminal(f))
+		}()
+		stopProgress = func() {
+			stop()
+			<-reported
+		}
+	}
+	start := time.Now()
+	slog.InfoContext(ctx, "extract started", "schemas", config.TargetSchema,
+		"parallelism", config.parallelism(), "onError", config.OnError)
+	err = extractor.Run(ctx, config.Db2DSN(), output)
+	// 最後の進み具合を、結果のメッセージより先に書きます
+	stopProgress()
+	if err != nil {
+		fmt.Fprintf(stderr, "Run error (%s)\n", config.Redact(err))
+		return exitExtract
+	}
+	slog.InfoContext(ctx, "extract finished", "duration", time.Since(start))
+	fmt.Fprintf(stderr, "Let's import %s into Mashu (^^)b\n", config.CSVFile)
+	return exitOK
+}
+
+// openOutput は、出力先を開きます。"-" の場合は stdout を返します。
+func openOutput(path string, stdout io.Writer) (io.Writer, func() error, error) {
+	if path == "-" {
+		return stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
 	}
+	return f, f.Close, nil
 }
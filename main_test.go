@@ -0,0 +1,218 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestConfig は、fake ドライバーに接続する設定ファイルを一時ディレクトリに作ります。
+func writeTestConfig(t *testing.T, config *Config) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := config.Save(path); err != nil {
+		t.Fatalf("Config.Save() error :%s", err)
+	}
+	return path
+}
+
+func testConfig() *Config {
+	return &Config{
+		Hostname:     "localhost",
+		Database:     "LUW",
+		Port:         50000,
+		UserID:       "db2inst1",
+		Password:     "password",
+		Lang:         "ja",
+		Remarks:      []string{"Alias", "Description"},
+		CSVFile:      "testdata/mashu.csv",
+		SystemSchema: "SYSCAT",
+	}
+}
+
+func TestRunListExtractors(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(), []string{"list-extractors"}, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("run() = %d, stderr: %s", code, stderr)
+	}
+	for _, name := range []string{"SYSCAT ", "QSYS2 ", "SYSIBM ", "auto ", "mashu\n", "json\n"} {
+		if !strings.Contains(stdout.String(), "  "+name) {
+			t.Errorf("list-extractors output lacks %s:\n%s", name, stdout)
+		}
+	}
+}
+
+func TestRunValidateConfig(t *testing.T) {
+	config := testConfig()
+	path := writeTestConfig(t, config)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", path, "validate-config"}, stdout, stderr)
+	if code != exitOK {
+		t.Errorf("run() = %d, stderr: %s", code, stderr)
+	}
+
+	stderr.Reset()
+	code = run(context.Background(),
+		[]string{"-config", path, "validate-config", "-system-schema", "SYSXXX", "-format", "xml",
+			"-log-level", "verbose"},
+		stdout, stderr)
+	if code != exitInvalidConfig {
+		t.Errorf("run() = %d, want %d", code, exitInvalidConfig)
+	}
+	for _, str := range []string{`systemSchema "SYSXXX"`, `format "xml"`, `logLevel "verbose"`} {
+		if !strings.Contains(stderr.String(), str) {
+			t.Errorf("validate-config output lacks %s:\n%s", str, stderr)
+		}
+	}
+}
+
+func TestRunExtract(t *testing.T) {
+	path := writeTestConfig(t, testConfig())
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", path, "-schema", "DB2INST1", "extract", "-o", "-"},
+		stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("run() = %d, stderr: %s", code, stderr)
+	}
+	assertGolden(t, "testdata/golden/db2.csv", stdout.Bytes())
+}
+
+func TestRunExtractError(t *testing.T) {
+	path := writeTestConfig(t, testConfig())
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", path, "-schema", "BROKEN,DB2INST1", "-on-error", "keep-going", "extract", "-o", "-"},
+		stdout, stderr)
+	if code != exitExtract {
+		t.Errorf("run() = %d, want %d", code, exitExtract)
+	}
+	if !strings.Contains(stderr.String(), "schema BROKEN: query SELECT") {
+		t.Errorf("stderr lacks the failed schema:\n%s", stderr)
+	}
+	if strings.Contains(stderr.String(), "Let's import") {
+		t.Errorf("stderr suggests import after error:\n%s", stderr)
+	}
+	if !strings.Contains(stdout.String(), "EMPLOYEE") {
+		t.Errorf("keep-going output lacks EMPLOYEE:\n%s", stdout)
+	}
+}
+
+func TestRunExtractProgress(t *testing.T) {
+	path := writeTestConfig(t, testConfig())
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", path, "-schema", "DB2INST1", "-progress", "extract", "-o", "-"},
+		stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("run() = %d, stderr: %s", code, stderr)
+	}
+	want := `progress schema="DB2INST1" tables_total=3 tables_done=3 columns=`
+	if !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr lacks %s:\n%s", want, stderr)
+	}
+	assertGolden(t, "testdata/golden/db2.csv", stdout.Bytes())
+}
+
+func TestRunExtractLog(t *testing.T) {
+	path := writeTestConfig(t, testConfig())
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", path, "-schema", "DB2INST1", "-log-level", "trace", "-log-format", "json",
+			"extract", "-o", "-"},
+		stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("run() = %d, stderr: %s", code, stderr)
+	}
+	for _, want := range []string{`"level":"TRACE","msg":"query","sql":"SELECT `, `"msg":"extract finished"`} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr lacks %s:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestRunExtractAuto(t *testing.T) {
+	tests := []struct {
+		database string
+		golden   string
+		platform string
+	}{
+		{"LUW", "testdata/golden/db2.csv", "Db2 for LUW"},
+		{"IBMI", "testdata/golden/db2i.csv", "Db2 for i"},
+		{"ZOS", "testdata/golden/db2z.csv", "Db2 for z/OS"},
+	}
+	for _, tt := range tests {
+		config := testConfig()
+		config.Database = tt.database
+		config.SystemSchema = AutoSystemSchema
+		config.TargetSchema = []string{"DB2INST1"}
+		path := writeTestConfig(t, config)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run(context.Background(), []string{"-config", path, "-o", "-"}, stdout, stderr)
+		if code != exitOK {
+			t.Fatalf("run() = %d, stderr: %s", code, stderr)
+		}
+		if !strings.Contains(stderr.String(), "detected "+tt.platform) {
+			t.Errorf("stderr lacks detected platform %s:\n%s", tt.platform, stderr)
+		}
+		assertGolden(t, tt.golden, stdout.Bytes())
+	}
+}
+
+func TestRunSchemas(t *testing.T) {
+	path := writeTestConfig(t, testConfig())
+	want := []string{"DB2INST1", "NULLID", "SYSCAT", "SYSIBM", "SYSTOOLS"}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", path, "schemas", "-o", "-"}, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("run() = %d, stderr: %s", code, stderr)
+	}
+	if got := strings.Fields(stdout.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schemas output = %#v, want %#v", got, want)
+	}
+
+	// コマンドを省略すると、targetSchema が空なので設定ファイルを書き換えます
+	code = run(context.Background(), []string{"-config", path}, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("run() = %d, stderr: %s", code, stderr)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error :%s", err)
+	}
+	if !reflect.DeepEqual(config.TargetSchema, want) {
+		t.Errorf("targetSchema = %#v, want %#v", config.TargetSchema, want)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(),
+		[]string{"-config", filepath.Join(t.TempDir(), "none.json"), "extract"}, stdout, stderr)
+	if code != exitReadConfig {
+		t.Errorf("run() = %d, want %d", code, exitReadConfig)
+	}
+
+	path := writeTestConfig(t, testConfig())
+	code = run(context.Background(), []string{"-config", path, "export"}, stdout, stderr)
+	if code != exitUsage {
+		t.Errorf("run() = %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"io"
+)
+
+func init() {
+	registerWriter("mashu", newMashuWriter)
+}
+
+// MashuWriter は、Metadata を Mashu のインポート用 CSV で出力します。
+type MashuWriter struct {
+	out         io.Writer
+	annotations []string
+}
+
+// newMashuWriter は、MashuWriter を作ります。NewMetadataWriter の実装です。
+func newMashuWriter(out io.Writer, config *Config) MetadataWriter {
+	return &MashuWriter{out: out, annotations: config.Annotations}
+}
+
+// Write は、Metadata を 1 件書き出します。MetadataWriter の実装です。
+// Config.Annotations で指定された情報は、説明に追記します。
+func (w *MashuWriter) Write(m *Metadata) error {
+	_, err := io.WriteString(w.out, m.Annotate(w.annotations).ToCSVString())
+	return err
+}
+
+// Close は、出力を完了します。MetadataWriter の実装です。
+func (w *MashuWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testMetadata は、出力形式のテストに使う Metadata です。
+func testMetadata() []Metadata {
+	return []Metadata{
+		{
+			Name:        "EMPLOYEE",
+			FormalName:  "DB2INST1.EMPLOYEE",
+			Alias:       "従業員",
+			Description: "氏名, 所属部門 <社外秘>",
+			MetaType:    1,
+			Lang:        "ja",
+			Columns: []Column{
+				{Name: "EMPNO", Alias: "社員番号", Type: "CHARACTER", Mode: 1, Order: 1,
+					KeyType: KeyType{Constraint: 1, Order: 1}},
+				{Name: "SALARY", Alias: "給与", Type: "DECIMAL", Order: 2},
+			},
+		},
+		{
+			Name:       "DEPARTMENT",
+			FormalName: "DB2INST1.DEPARTMENT",
+			MetaType:   1,
+			Lang:       "ja",
+			Columns:    []Column{{Name: "DEPTNO", Type: "CHARACTER", Mode: 1, Order: 1}},
+		},
+	}
+}
+
+// writeTestMetadata は、指定した形式で testMetadata を出力します。
+func writeTestMetadata(t *testing.T, format string, list []Metadata) []byte {
+	t.Helper()
+	input := make(chan MetadataInProcess, len(list))
+	for _, m := range list {
+		input <- MetadataInProcess{Data: m}
+	}
+	close(input)
+
+	out := &bytes.Buffer{}
+	err := writeMetadata(context.Background(), input, out, &Config{Format: format})
+	if err != nil {
+		t.Fatalf("writeMetadata() error :%s", err)
+	}
+	return out.Bytes()
+}
+
+func TestWriterNames(t *testing.T) {
+	want := []string{"ddl", "json", "jsonl", "mashu"}
+	if got := WriterNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("WriterNames() = %#v, want %#v", got, want)
+	}
+	if GetWriter("") == nil {
+		t.Errorf("GetWriter(\"\") = nil, want default writer")
+	}
+	err := writeMetadata(context.Background(), nil, &bytes.Buffer{}, &Config{Format: "xml"})
+	if err == nil {
+		t.Errorf("writeMetadata() with unknown format error = nil")
+	}
+}
+
+func TestMashuWriter(t *testing.T) {
+	list := testMetadata()
+	want := ""
+	for _, m := range list {
+		want += m.ToCSVString()
+	}
+	if got := string(writeTestMetadata(t, "", list)); got != want {
+		t.Errorf("mashu output = %q, want %q", got, want)
+	}
+}
+
+func TestJSONLinesWriter(t *testing.T) {
+	list := testMetadata()
+	out := writeTestMetadata(t, "jsonl", list)
+
+	got := []Metadata{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var m Metadata
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("json.Unmarshal() error :%s\n%s", err, scanner.Bytes())
+		}
+		got = append(got, m)
+	}
+	if !reflect.DeepEqual(got, list) {
+		t.Errorf("jsonl round trip mismatch\n got: %#v\nwant: %#v", got, list)
+	}
+	if !bytes.Contains(out, []byte(`<社外秘>`)) {
+		t.Errorf("jsonl output escapes HTML: %s", out)
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	list := testMetadata()
+	out := writeTestMetadata(t, "json", list)
+
+	var got []Metadata
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error :%s\n%s", err, out)
+	}
+	if !reflect.DeepEqual(got, list) {
+		t.Errorf("json round trip mismatch\n got: %#v\nwant: %#v", got, list)
+	}
+
+	empty := writeTestMetadata(t, "json", nil)
+	if string(empty) != "[]\n" {
+		t.Errorf("empty json output = %q, want %q", empty, "[]\n")
+	}
+}
+
+func TestDDLWriter(t *testing.T) {
+	list := testMetadata()
+	list[0].Columns = append(list[0].Columns,
+		Column{Name: "ROW_ID", Type: "INTEGER", Mode: 1, Order: 3,
+			Identity: &Identity{Generation: "BY DEFAULT", Start: "1", Increment: "1"}},
+		Column{Name: "HIRE\"DATE", Type: "DATE", Order: 4, Default: "CURRENT DATE"})
+	list[0].Constraints = []Constraint{{Name: "PK_EMPLOYEE", Type: 1, Columns: []string{"EMPNO"}}}
+	out := string(writeTestMetadata(t, "ddl", list[:1]))
+
+	for _, str := range []string{
+		`CREATE TABLE "DB2INST1"."EMPLOYEE" (`,
+		`"EMPNO" CHARACTER NOT NULL,`,
+		`"ROW_ID" INTEGER NOT NULL GENERATED BY DEFAULT AS IDENTITY (START WITH 1, INCREMENT BY 1),`,
+		`"HIRE""DATE" DATE DEFAULT CURRENT DATE,`,
+		`CONSTRAINT "PK_EMPLOYEE" PRIMARY KEY ("EMPNO")`,
+		`COMMENT ON TABLE "DB2INST1"."EMPLOYEE" IS '氏名, 所属部門 <社外秘>';`,
+	} {
+		if !strings.Contains(out, str) {
+			t.Errorf("ddl output lacks %s:\n%s", str, out)
+		}
+	}
+}
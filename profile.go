@@ -0,0 +1,370 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultProfileRows は、Config.ProfileRows が 0 の場合のテーブルごとの標本の行数です。
+	defaultProfileRows = 1000
+	// defaultProfileTimeout は、Config.ProfileTimeout が 0 の場合の秒数です。
+	defaultProfileTimeout = 60
+	// profileTopValues は、ColumnProfile.TopValues に残す値の数です。
+	profileTopValues = 5
+)
+
+// ColumnProfile は、標本のデータから作ったカラムのプロファイルです。
+type ColumnProfile struct {
+	// Rows は、標本の行数です。
+	Rows int `json:"rows"`
+	// NullRatio は、標本に占める NULL の割合です。
+	NullRatio float64 `json:"nullRatio"`
+	// Distinct は、テーブル全体の異なる値の数の推定値です。
+	Distinct int64 `json:"distinct"`
+	// Min は、標本の最小値です。
+	Min string `json:"min,omitempty"`
+	// Max は、標本の最大値です。
+	Max string `json:"max,omitempty"`
+	// TopValues は、標本に 2 回以上現れた値を出現回数の多い順に並べたものです。
+	TopValues []ValueCount `json:"topValues,omitempty"`
+	// Formats は、文字列のカラムの値がすべて一致した書式です。
+	Formats []string `json:"formats,omitempty"`
+}
+
+// ValueCount は、値とその出現回数です。
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Notes は、プロファイルの要約を 1 項目 1 行で返します。
+func (p ColumnProfile) Notes() []string {
+	notes := []string{fmt.Sprintf("Profile: %d rows, %s%% null, ~%d distinct",
+		p.Rows, strconv.FormatFloat(p.NullRatio*100, 'f', -1, 64), p.Distinct)}
+	if p.Min != "" || p.Max != "" {
+		notes = append(notes, fmt.Sprintf("Sample Range: %s - %s", p.Min, p.Max))
+	}
+	if len(p.TopValues) > 0 {
+		list := make([]string, len(p.TopValues))
+		for i, v := range p.TopValues {
+			list[i] = fmt.Sprintf("%s (%d)", v.Value, v.Count)
+		}
+		notes = append(notes, "Top Values: "+strings.Join(list, ", "))
+	}
+	if len(p.Formats) > 0 {
+		notes = append(notes, "Formats: "+strings.Join(p.Formats, ", "))
+	}
+	return notes
+}
+
+// unprofiledTypes は、プロファイルを作らないデータ型です。
+var unprofiledTypes = map[string]bool{
+	"BLOB": true, "CLOB": true, "DBCLOB": true, "NCLOB": true, "XML": true,
+	"BINARY": true, "VARBINARY": true, "VARBIN": true, "ROWID": true, "DATALINK": true,
+	"LONG VARCHAR": true, "LONGVAR": true, "LONG VARGRAPHIC": true, "LONGVARG": true,
+}
+
+// numericTypes は、最小値と最大値を数値として比較するデータ型です。
+var numericTypes = map[string]bool{
+	"SMALLINT": true, "INTEGER": true, "INT": true, "BIGINT": true, "DECIMAL": true,
+	"NUMERIC": true, "REAL": true, "DOUBLE": true, "FLOAT": true, "DECFLOAT": true,
+}
+
+// valueFormats は、文字列のカラムに格納された値を判定する書式です。
+var valueFormats = []struct {
+	name  string
+	match func(str string) bool
+}{
+	{"DATE(YYYY-MM-DD)", matchTime("2006-01-02")},
+	{"DATE(YYYYMMDD)", matchTime("20060102")},
+	{"DATE(YYYY/MM/DD)", matchTime("2006/01/02")},
+	{"TIME(HH:MM:SS)", matchTime("15:04:05")},
+	{"TIMESTAMP", matchTime("2006-01-02-15.04.05", "2006-01-02 15:04:05", "2006-01-02T15:04:05")},
+	{"INTEGER", regexp.MustCompile(`^[+-]?[0-9]+$`).MatchString},
+	{"DECIMAL", regexp.MustCompile(`^[+-]?[0-9]*\.[0-9]+$`).MatchString},
+}
+
+// matchTime は、値がいずれかのレイアウトで日時として解釈できるかを判定する関数を返します。
+func matchTime(layouts ...string) func(str string) bool {
+	return func(str string) bool {
+		for _, layout := range layouts {
+			if _, err := time.Parse(layout, str); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// profiler は、テーブルのデータを標本にしてカラムのプロファイルを作ります。
+// スキーマごとのパイプラインから並行して呼ばれます。
+type profiler struct {
+	db      *sql.DB
+	rows    int
+	timeout time.Duration
+
+	once     sync.Once
+	deadline time.Time
+}
+
+// newProfiler は、Config の行数と時間の上限で profiler を作ります。
+// 時間の上限は、最初に profile を呼んだ時点から数えます。
+func newProfiler(db *sql.DB, config *Config) *profiler {
+	rows := config.ProfileRows
+	if rows == 0 {
+		rows = defaultProfileRows
+	}
+	timeout := config.ProfileTimeout
+	if timeout == 0 {
+		timeout = defaultProfileTimeout
+	}
+	return &profiler{
+		db:      db,
+		rows:    rows,
+		timeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+// samplePercent は、行数が rowCount のテーブルから p.rows 行を取り出すのに必要な
+// 標本の割合(百分率)を返します。ページ単位の標本で不足しないように 2 倍にします。
+// 行数が不明な場合や、テーブル全体が上限に収まる場合は 0 を返します。
+func (p *profiler) samplePercent(rowCount int64) float64 {
+	if rowCount <= int64(p.rows) {
+		return 0
+	}
+	percent := math.Ceil(float64(p.rows)*200/float64(rowCount)*1000) / 1000
+	if percent >= 100 {
+		return 0
+	}
+	return percent
+}
+
+// profile は、meta のテーブルから最大 p.rows 行の標本を取り、カラムに Profile を設定します。
+// sample は FROM 句のテーブルの後ろに付ける TABLESAMPLE 句などで、rowCount はテーブルの
+// 行数(不明な場合は -1)です。時間の上限を過ぎた後のテーブルは、標本を取らずに
+// 上限を過ぎたことをエラーで返します。呼び出し側は、Warning にしてテーブルを流します。
+func (p *profiler) profile(ctx context.Context, meta *Metadata, sample string, rowCount int64) error {
+	p.once.Do(func() { p.deadline = time.Now().Add(p.timeout) })
+	if !time.Now().Before(p.deadline) {
+		return fmt.Errorf("profile skipped: profileTimeout %s exceeded", p.timeout)
+	}
+	err := p.sample(ctx, meta, sample, rowCount)
+	if err != nil && ctx.Err() == nil && !time.Now().Before(p.deadline) {
+		return fmt.Errorf("profile stopped: profileTimeout %s exceeded", p.timeout)
+	}
+	return err
+}
+
+// sample は、profile の実装です。
+func (p *profiler) sample(ctx context.Context, meta *Metadata, sample string, rowCount int64) error {
+	indexes := []int{}
+	names := []string{}
+	columns := []*columnProfiler{}
+	for i, col := range meta.Columns {
+		typ := strings.TrimPrefix(col.Type, "SYSIBM.")
+		if col.ForBitData || unprofiledTypes[typ] || strings.Contains(typ, ".") {
+			continue
+		}
+		indexes = append(indexes, i)
+		names = append(names, col.Name)
+		columns = append(columns, newColumnProfiler(typ))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, p.deadline)
+	defer cancel()
+	if sample != "" {
+		sample = " " + sample
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s%s FETCH FIRST %d ROWS ONLY",
+		quoteIdentifiers(names), quoteFormalName(meta.FormalName), sample, p.rows)
+	trace := startQuery(ctx, stmt)
+	rows, err := p.db.QueryContext(ctx, stmt)
+	if err != nil {
+		trace.done(0, err)
+		return queryError(stmt, err)
+	}
+	defer rows.Close()
+	n := 0
+	defer func() { trace.done(n, err) }()
+
+	values := make([]sql.NullString, len(names))
+	pointers := make([]interface{}, len(names))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for ; n < p.rows && rows.Next(); n++ {
+		trace.row()
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return queryError(stmt, err)
+		}
+		for i, v := range values {
+			columns[i].add(v)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return queryError(stmt, err)
+	}
+	for i, c := range columns {
+		meta.Columns[indexes[i]].Profile = c.result(rowCount)
+		meta.Columns[indexes[i]].samples = c.values()
+	}
+	return nil
+}
+
+// columnProfiler は、1 カラム分の標本の値を集計します。
+type columnProfiler struct {
+	numeric bool
+	text    bool
+	rows    int
+	nulls   int
+	counts  map[string]int
+	min     string
+	max     string
+	// formats は、valueFormats のそれぞれに、これまでの値がすべて一致したかどうかです。
+	formats []bool
+	checked int
+}
+
+// newColumnProfiler は、データ型 typ のカラムの columnProfiler を作ります。
+func newColumnProfiler(typ string) *columnProfiler {
+	kind := typeKind(typ)
+	c := &columnProfiler{
+		numeric: numericTypes[typ],
+		text:    kind == typeKindCharacter || kind == typeKindString,
+		counts:  make(map[string]int),
+	}
+	if c.text {
+		c.formats = make([]bool, len(valueFormats))
+		for i := range c.formats {
+			c.formats[i] = true
+		}
+	}
+	return c
+}
+
+// add は、標本の値を 1 つ集計します。固定長の文字列の後ろの空白は取り除きます。
+func (c *columnProfiler) add(v sql.NullString) {
+	c.rows++
+	if !v.Valid {
+		c.nulls++
+		return
+	}
+	str := strings.TrimRight(v.String, " ")
+	if c.counts[str] == 0 {
+		if len(c.counts) == 0 || c.less(str, c.min) {
+			c.min = str
+		}
+		if len(c.counts) == 0 || c.less(c.max, str) {
+			c.max = str
+		}
+	}
+	c.counts[str]++
+
+	if c.text && strings.TrimSpace(str) != "" {
+		c.checked++
+		for i, f := range valueFormats {
+			if c.formats[i] && !f.match(strings.TrimSpace(str)) {
+				c.formats[i] = false
+			}
+		}
+	}
+}
+
+// less は、値 a が b より小さいかどうかを返します。数値型は数値として比較します。
+func (c *columnProfiler) less(a, b string) bool {
+	if c.numeric {
+		x, errX := strconv.ParseFloat(a, 64)
+		y, errY := strconv.ParseFloat(b, 64)
+		if errX == nil && errY == nil {
+			return x < y
+		}
+	}
+	return a < b
+}
+
+// result は、集計結果から ColumnProfile を作ります。rowCount はテーブルの行数です。
+func (c *columnProfiler) result(rowCount int64) *ColumnProfile {
+	p := &ColumnProfile{
+		Rows:     c.rows,
+		Distinct: estimateDistinct(c.counts, c.rows, rowCount),
+		Min:      c.min,
+		Max:      c.max,
+	}
+	if c.rows > 0 {
+		p.NullRatio = math.Round(float64(c.nulls)/float64(c.rows)*10000) / 10000
+	}
+
+	for v, n := range c.counts {
+		if n > 1 {
+			p.TopValues = append(p.TopValues, ValueCount{Value: v, Count: n})
+		}
+	}
+	sort.Slice(p.TopValues, func(i, j int) bool {
+		a, b := p.TopValues[i], p.TopValues[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Value < b.Value
+	})
+	if len(p.TopValues) > profileTopValues {
+		p.TopValues = p.TopValues[:profileTopValues]
+	}
+
+	if c.checked > 0 {
+		for i, f := range valueFormats {
+			if c.formats[i] {
+				p.Formats = append(p.Formats, f.name)
+			}
+		}
+	}
+	return p
+}
+
+// values は、標本の NULL 以外の異なる値をソートして返します。
+func (c *columnProfiler) values() []string {
+	list := make([]string, 0, len(c.counts))
+	for v := range c.counts {
+		list = append(list, v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// estimateDistinct は、標本の値の出現回数からテーブル全体の異なる値の数を推定します。
+// 標本がテーブルの一部の場合は GEE 推定量 sqrt(N/n)*f1 + (d - f1) を使います。
+// f1 は標本に 1 回だけ現れた値の数、d は標本の異なる値の数です。
+func estimateDistinct(counts map[string]int, rows int, rowCount int64) int64 {
+	d := int64(len(counts))
+	if rows == 0 || rowCount <= int64(rows) {
+		return d
+	}
+	f1 := 0
+	for _, n := range counts {
+		if n == 1 {
+			f1++
+		}
+	}
+	estimate := int64(math.Round(math.Sqrt(float64(rowCount)/float64(rows))*float64(f1))) + d - int64(f1)
+	if estimate > rowCount {
+		return rowCount
+	}
+	return estimate
+}
@@ -0,0 +1,110 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnProfiler(t *testing.T) {
+	// values は、NULL を nil で表した標本の値です。
+	values := func(list ...interface{}) []sql.NullString {
+		result := []sql.NullString{}
+		for _, v := range list {
+			if v == nil {
+				result = append(result, sql.NullString{})
+			} else {
+				result = append(result, sql.NullString{String: v.(string), Valid: true})
+			}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		typ      string
+		values   []sql.NullString
+		rowCount int64
+		want     *ColumnProfile
+	}{
+		{"numeric range", "DECIMAL",
+			values("9.5", "10.25", nil, "9.5", "-3"), -1,
+			&ColumnProfile{Rows: 5, NullRatio: 0.2, Distinct: 3, Min: "-3", Max: "10.25",
+				TopValues: []ValueCount{{"9.5", 2}}}},
+		{"date in char", "CHARACTER",
+			values("20240401  ", "20231231  ", "          ", "20240401  "), 4,
+			&ColumnProfile{Rows: 4, Distinct: 3, Min: "", Max: "20240401",
+				TopValues: []ValueCount{{"20240401", 2}}, Formats: []string{"DATE(YYYYMMDD)", "INTEGER"}}},
+		{"timestamp in varchar", "SYSIBM.VARCHAR",
+			values("2024-04-01-10.00.00.000000", "2024-04-02 03:00:00"), 1000,
+			&ColumnProfile{Rows: 2, Distinct: 45, Min: "2024-04-01-10.00.00.000000",
+				Max: "2024-04-02 03:00:00", Formats: []string{"TIMESTAMP"}}},
+		{"all null", "VARCHAR",
+			values(nil, nil), 10,
+			&ColumnProfile{Rows: 2, NullRatio: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newColumnProfiler(tt.typ)
+			for _, v := range tt.values {
+				c.add(v)
+			}
+			if got := c.result(tt.rowCount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfilerSamplePercent(t *testing.T) {
+	p := &profiler{rows: 1000}
+	tests := []struct {
+		rowCount int64
+		want     float64
+	}{
+		{-1, 0},
+		{1000, 0},
+		{1500, 0},
+		{3000, 66.667},
+		{1000000000, 0.001},
+	}
+	for _, tt := range tests {
+		if got := p.samplePercent(tt.rowCount); got != tt.want {
+			t.Errorf("samplePercent(%d) = %v, want %v", tt.rowCount, got, tt.want)
+		}
+	}
+}
+
+func TestColumnProfileNotes(t *testing.T) {
+	p := ColumnProfile{Rows: 10, NullRatio: 0.125, Distinct: 15, Min: "A00", Max: "E21",
+		TopValues: []ValueCount{{"A00", 2}, {"B01", 2}}, Formats: []string{"INTEGER"}}
+	want := []string{"Profile: 10 rows, 12.5% null, ~15 distinct", "Sample Range: A00 - E21",
+		"Top Values: A00 (2), B01 (2)", "Formats: INTEGER"}
+	if got := p.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %#v, want %#v", got, want)
+	}
+}
+
+func TestProfilerTimeout(t *testing.T) {
+	// 時間の上限は、profiler を作った時点ではなく最初の profile から数えます
+	p := newProfiler(nil, &Config{ProfileTimeout: 60})
+	if !p.deadline.IsZero() {
+		t.Errorf("newProfiler() deadline = %s, want zero", p.deadline)
+	}
+
+	p = &profiler{rows: 10}
+	meta := &Metadata{FormalName: "S.T", Columns: []Column{{Name: "A", Type: "SYSIBM.INTEGER"}}}
+	err := p.profile(context.Background(), meta, "", -1)
+	if err == nil || !strings.Contains(err.Error(), "profileTimeout") {
+		t.Errorf("profile() error = %v, want profileTimeout exceeded", err)
+	}
+	if meta.Columns[0].Profile != nil {
+		t.Errorf("profile() Profile = %#v, want nil", meta.Columns[0].Profile)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// progressInterval は、端末のプログレスバーを書き換える間隔です。
+	progressInterval = 200 * time.Millisecond
+	// progressLogInterval は、端末でない場合に進み具合の行を書く間隔です。
+	progressLogInterval = 10 * time.Second
+	// progressBarWidth は、プログレスバーの文字数です。
+	progressBarWidth = 30
+)
+
+// Progress は、抽出の進み具合を数えます。パイプラインのステージから並行して呼ばれます。
+// nil の Progress は何も数えません。
+type Progress struct {
+	start  time.Time
+	total  atomic.Int64
+	done   atomic.Int64
+	column atomic.Int64
+
+	mu     sync.Mutex
+	schema string
+}
+
+// NewProgress は、現在時刻から数え始める Progress を作ります。
+func NewProgress() *Progress {
+	return &Progress{start: time.Now()}
+}
+
+// addTotal は、抽出の対象のテーブルの数に n を加えます。抽出を始める前に、
+// カタログで数えた対象スキーマのテーブルの数を加えます。
+func (p *Progress) addTotal(n int64) {
+	if p != nil {
+		p.total.Add(n)
+	}
+}
+
+// tableDone は、meta の処理を終えたことを数えます。別名と、テーブル以外の
+// オブジェクトは、対象のテーブルの数に含まれないため数えません。
+func (p *Progress) tableDone(meta *Metadata) {
+	if p == nil || meta.MetaType != 1 || meta.BaseObject != "" {
+		return
+	}
+	p.done.Add(1)
+	p.column.Add(int64(len(meta.Columns)))
+}
+
+// setSchema は、出力中のスキーマを設定します。
+func (p *Progress) setSchema(schema string) {
+	if p != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.schema = schema
+	}
+}
+
+// snapshot は、現在の進み具合を返します。
+func (p *Progress) snapshot() progressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return progressSnapshot{
+		schema:  p.schema,
+		total:   p.total.Load(),
+		done:    p.done.Load(),
+		columns: p.column.Load(),
+		elapsed: time.Since(p.start),
+	}
+}
+
+// Report は、ctx が取り消されるまで進み具合を w に書き、最後の進み具合を書いて終わります。
+// tty が真の場合は同じ行を書き換えるプログレスバー、偽の場合は一定の間隔で
+// key=value 形式の行を書きます。
+func (p *Progress) Report(ctx context.Context, w io.Writer, tty bool) {
+	interval := progressLogInterval
+	if tty {
+		interval = progressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if tty {
+				fmt.Fprintf(w, "\r%s\x1b[K\n", p.snapshot().bar())
+			} else {
+				fmt.Fprintln(w, p.snapshot().line())
+			}
+			return
+		case <-ticker.C:
+			if tty {
+				fmt.Fprintf(w, "\r%s\x1b[K", p.snapshot().bar())
+			} else {
+				fmt.Fprintln(w, p.snapshot().line())
+			}
+		}
+	}
+}
+
+// progressSnapshot は、ある時点の進み具合です。
+type progressSnapshot struct {
+	schema  string
+	total   int64
+	done    int64
+	columns int64
+	elapsed time.Duration
+}
+
+// rate は、1 秒あたりに処理したテーブルの数を返します。
+func (s progressSnapshot) rate() float64 {
+	if s.elapsed <= 0 {
+		return 0
+	}
+	return float64(s.done) / s.elapsed.Seconds()
+}
+
+// eta は、対象のテーブルの処理を終えるまでの残り時間の見込みを返します。
+// まだ 1 つも処理していない場合や、テーブルの数が分からない場合は偽を返します。
+func (s progressSnapshot) eta() (time.Duration, bool) {
+	rate := s.rate()
+	if rate == 0 || s.total == 0 {
+		return 0, false
+	}
+	rest := s.total - s.done
+	if rest < 0 {
+		rest = 0
+	}
+	return time.Duration(float64(rest) / rate * float64(time.Second)).Round(time.Second), true
+}
+
+// etaString は、残り時間の見込みを文字列で返します。見込みがない場合は ? です。
+func (s progressSnapshot) etaString() string {
+	if eta, ok := s.eta(); ok {
+		return eta.String()
+	}
+	return "?"
+}
+
+// bar は、端末に表示するプログレスバーの 1 行を返します。
+func (s progressSnapshot) bar() string {
+	filled := 0
+	if s.total > 0 {
+		filled = int(s.done * progressBarWidth / s.total)
+	}
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return fmt.Sprintf("[%s%s] %d/%d tables, %d columns, %s, %.1f tables/s, ETA %s",
+		strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled),
+		s.done, s.total, s.columns, s.schema, s.rate(), s.etaString())
+}
+
+// line は、端末でない場合に書く key=value 形式の 1 行を返します。
+func (s progressSnapshot) line() string {
+	return fmt.Sprintf("progress schema=%q tables_total=%d tables_done=%d columns=%d rate=%.1f eta=%s elapsed=%s",
+		s.schema, s.total, s.done, s.columns, s.rate(), s.etaString(), s.elapsed.Round(time.Second))
+}
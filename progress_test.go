@@ -0,0 +1,57 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressSnapshot(t *testing.T) {
+	s := progressSnapshot{schema: "DB2INST1", total: 40, done: 10, columns: 123, elapsed: 5 * time.Second}
+	want := "[#######.......................] 10/40 tables, 123 columns, DB2INST1, 2.0 tables/s, ETA 15s"
+	if got := s.bar(); got != want {
+		t.Errorf("bar() = %s, want %s", got, want)
+	}
+	want = `progress schema="DB2INST1" tables_total=40 tables_done=10 columns=123 rate=2.0 eta=15s elapsed=5s`
+	if got := s.line(); got != want {
+		t.Errorf("line() = %s, want %s", got, want)
+	}
+
+	s = progressSnapshot{schema: "DB2INST1", total: 40}
+	if got := s.etaString(); got != "?" {
+		t.Errorf("etaString() = %s, want ?", got)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var p *Progress
+	// nil の Progress は何もしません
+	p.addTotal(1)
+	p.tableDone(&Metadata{MetaType: 1})
+	p.setSchema("S")
+
+	p = NewProgress()
+	p.setSchema("S")
+	p.addTotal(2)
+	p.tableDone(&Metadata{MetaType: 1, Columns: []Column{{Name: "A"}, {Name: "B"}}})
+	p.tableDone(&Metadata{MetaType: 1, BaseObject: "S.T"})
+	p.tableDone(&Metadata{MetaType: 2})
+	s := p.snapshot()
+	if s.schema != "S" || s.total != 2 || s.done != 1 || s.columns != 2 {
+		t.Errorf("snapshot() = %+v, want S 2 total 1 done 2 columns", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out := &bytes.Buffer{}
+	p.Report(ctx, out, false)
+	if got := out.String(); !strings.HasPrefix(got, `progress schema="S" tables_total=2 tables_done=1 columns=2 `) {
+		t.Errorf("Report() = %s", got)
+	}
+}
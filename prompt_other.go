@@ -0,0 +1,22 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal は、エコーを止められない環境では常に false を返します。
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+// readPassword は、この環境ではサポートしていません。
+func readPassword(f *os.File) (string, error) {
+	return "", errors.New("password prompt is not supported on this platform")
+}
@@ -9,14 +9,24 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// ColumnList は、対象テーブルの対象カラム名のリストを取得します。
+// ColumnList は、1 カラムの SELECT 文の結果を文字列のリストとして取得します。
+// 対象テーブルの対象カラム名のリストなどに使います。
 func ColumnList(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	trace := startQuery(ctx, query)
+	result, err := columnList(ctx, db, query, trace)
+	trace.done(len(result), err)
+	return result, queryError(query, err)
+}
+
+// columnList は、ColumnList のエラーを包まない実装です。
+func columnList(ctx context.Context, db *sql.DB, query string, trace *queryTrace) ([]string, error) {
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -25,11 +35,60 @@ func ColumnList(ctx context.Context, db *sql.DB, query string) ([]string, error)
 
 	result := []string{}
 	for rows.Next() {
+		trace.row()
 		var column string
-		rows.Scan(&column)
+		err = rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
 		result = append(result, column)
 	}
-	return result, nil
+	return result, rows.Err()
+}
+
+// QueryRows は、SELECT 文の結果を結果セットのカラム名をキーとする map として
+// 1 行ずつ fn に渡します。カラムが固定の結合クエリに使います。
+// エラーは、照会した SELECT 文の ExtractError にして返します。
+func QueryRows(ctx context.Context, db *sql.DB, stmt string,
+	fn func(m map[string]string) error) error {
+
+	trace := startQuery(ctx, stmt)
+	n := 0
+	err := queryRows(ctx, db, stmt, func(m map[string]string) error {
+		trace.row()
+		n++
+		return fn(m)
+	})
+	trace.done(n, err)
+	return queryError(stmt, err)
+}
+
+// queryRows は、QueryRows のエラーを包まない実装です。
+func queryRows(ctx context.Context, db *sql.DB, stmt string,
+	fn func(m map[string]string) error) error {
+
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	row := NewRow(names)
+	for rows.Next() {
+		err = rows.Scan(row.Values()...)
+		if err != nil {
+			return err
+		}
+		err = fn(row.Map())
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 // Query は、バージョン間でのカラム数の差異を動的に解決するクエリ
@@ -51,9 +110,51 @@ func (q *Query) Stmt() string {
 	return fmt.Sprintf("SELECT %s %s", q.row.Names(), q.stmt)
 }
 
-// Exec は、SELECT 文を DB に送ります。
+// Exec は、SELECT 文を DB に送ります。結果の行数は記録しないため、行を読む場合は
+// Open を使います。
 func (q *Query) Exec(ctx context.Context, db *sql.DB, args ...interface{}) (*sql.Rows, error) {
-	return db.QueryContext(ctx, q.Stmt(), args...)
+	trace := startQuery(ctx, q.Stmt())
+	rows, err := db.QueryContext(ctx, q.Stmt(), args...)
+	trace.done(-1, err)
+	return rows, queryError(q.Stmt(), err)
+}
+
+// Open は、SELECT 文を DB に送り、結果行を 1 行ずつ map として返す next と、
+// 結果セットを閉じる close を返します。next は、結果行の終わりで io.EOF を返します。
+// SQL 文は、送る時点と、close で読んだ行数とともに記録します。
+func (q *Query) Open(ctx context.Context, db *sql.DB, args ...interface{}) (
+	next func() (map[string]string, error), close func() error, err error) {
+
+	trace := startQuery(ctx, q.Stmt())
+	rows, err := db.QueryContext(ctx, q.Stmt(), args...)
+	if err != nil {
+		trace.done(0, err)
+		return nil, nil, queryError(q.Stmt(), err)
+	}
+	n := 0
+	var readErr error
+	next = func() (map[string]string, error) {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				readErr = err
+				return nil, queryError(q.Stmt(), err)
+			}
+			return nil, io.EOF
+		}
+		trace.row()
+		m, err := q.Scan(rows)
+		if err != nil {
+			readErr = err
+			return nil, queryError(q.Stmt(), err)
+		}
+		n++
+		return m, nil
+	}
+	close = func() error {
+		trace.done(n, readErr)
+		return rows.Close()
+	}
+	return next, close, nil
 }
 
 // Scan は、結果行を指定したカラム名をキーとする map として返します。
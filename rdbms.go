@@ -6,8 +6,16 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // DataSourceName は、ドライバが必要とする接続のための情報です。
@@ -26,6 +34,9 @@ type MetadataExtractor interface {
 	SetConfig(config *Config)
 }
 
+// sqlDriver は、sql.Open() に渡すドライバー名です。テストで差し替えます。
+var sqlDriver = Db2Driver
+
 var (
 	extractorsMu sync.RWMutex
 	extractors   = make(map[string]MetadataExtractor)
@@ -50,27 +61,719 @@ func GetExtractor(name string) MetadataExtractor {
 	return nil
 }
 
+// ExtractorNames は、登録されている MetadataExtractor の名前をソートして返します。
+func ExtractorNames() []string {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	names := make([]string, 0, len(extractors))
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AutoSystemSchema は、接続先から systemSchema を判定させる指定です。
+const AutoSystemSchema = "auto"
+
+// platforms は、systemSchema ごとの Db2 のプラットフォームと判定用の SQL 文です。
+// Db2 for LUW にも SYSIBM のカタログ表があるため、この順に判定します。
+var platforms = []struct {
+	systemSchema string
+	name         string
+	probe        string
+}{
+	{"SYSCAT", "Db2 for LUW", "SELECT 1 FROM SYSCAT.TABLES FETCH FIRST 1 ROWS ONLY"},
+	{"QSYS2", "Db2 for i", "SELECT 1 FROM QSYS2.SYSTABLES FETCH FIRST 1 ROWS ONLY"},
+	{"SYSIBM", "Db2 for z/OS", "SELECT 1 FROM SYSIBM.SYSTABLES FETCH FIRST 1 ROWS ONLY"},
+}
+
+// PlatformName は、systemSchema に対応する Db2 のプラットフォーム名を返します。
+func PlatformName(systemSchema string) string {
+	for _, p := range platforms {
+		if p.systemSchema == systemSchema {
+			return p.name
+		}
+	}
+	return systemSchema
+}
+
+// DetectSystemSchema は、接続先のカタログを確認して systemSchema を判定します。
+func DetectSystemSchema(ctx context.Context, dsn DataSourceName) (string, error) {
+	db, err := sql.Open(sqlDriver, dsn.DSN())
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	errs := []error{}
+	for _, p := range platforms {
+		if GetExtractor(Db2Driver+"."+p.systemSchema) == nil {
+			continue
+		}
+		trace := startQuery(ctx, p.probe)
+		rows, err := db.QueryContext(ctx, p.probe)
+		trace.done(-1, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.systemSchema, err))
+			continue
+		}
+		rows.Close()
+		return p.systemSchema, nil
+	}
+	return "", fmt.Errorf("cannot detect Db2 platform: %w", errors.Join(errs...))
+}
+
+// ResolveExtractor は、Config.SystemSchema に対応する MetadataExtractor と
+// systemSchema を返します。"auto" の場合は接続先から判定します。
+func ResolveExtractor(ctx context.Context, config *Config) (MetadataExtractor, string, error) {
+	systemSchema := config.SystemSchema
+	if strings.EqualFold(systemSchema, AutoSystemSchema) {
+		var err error
+		systemSchema, err = DetectSystemSchema(ctx, config.Db2DSN())
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	extractor := GetExtractor(Db2Driver + "." + systemSchema)
+	if extractor == nil {
+		return nil, "", fmt.Errorf("unknown systemSchema: %s", systemSchema)
+	}
+	extractor.SetConfig(config)
+	return extractor, systemSchema, nil
+}
+
 // MetadataInProcess は、Pipeline を流れる Metadata と error です。
+// Data.FormalName が空で Warnings だけを持つものは、警告だけを伝えます。
 type MetadataInProcess struct {
-	Data Metadata
-	Err  error
+	Data     Metadata
+	Err      error
+	Warnings []Warning
+}
+
+// Warning は、抽出を続けられる問題です。
+type Warning struct {
+	// Stage は、問題を検出したステージ(columns など)です。
+	Stage string `json:"stage"`
+	// Object は、問題のあったオブジェクトの正式名です。
+	Object string `json:"object"`
+	// Message は、問題の内容です。
+	Message string `json:"message"`
+}
+
+// String は、警告を "stage: object: message" の形式で返します。
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s: %s", w.Stage, w.Object, w.Message)
+}
+
+// send は、ctx が取り消されていなければ mip を output に送ります。送れた場合は真を返します。
+func send(ctx context.Context, output chan<- MetadataInProcess, mip MetadataInProcess) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case output <- mip:
+		return true
+	}
+}
+
+// ExtractError は、抽出に失敗したスキーマ、テーブル、カタログの照会と原因のエラーです。
+// カタログ全体の照会のエラーは Table が空です。
+type ExtractError struct {
+	Schema string
+	Table  string
+	Query  string
+	Err    error
+}
+
+// Error は、error インターフェースの実装です。
+func (e *ExtractError) Error() string {
+	list := []string{}
+	if e.Schema != "" {
+		list = append(list, "schema "+e.Schema)
+	}
+	if e.Table != "" {
+		list = append(list, "table "+e.Table)
+	}
+	if e.Query != "" {
+		list = append(list, "query "+strings.Join(strings.Fields(e.Query), " "))
+	}
+	return strings.Join(append(list, e.Err.Error()), ": ")
+}
+
+// Unwrap は、原因のエラーを返します。
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// queryError は、照会 stmt のエラーを ExtractError にします。err が nil の場合や
+// ExtractError を含む場合は、そのまま返します。
+func queryError(stmt string, err error) error {
+	var e *ExtractError
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &ExtractError{Query: stmt, Err: err}
+}
+
+// enrichMetadata は、input の Metadata に情報を追加して流すステージです。
+// load は最初に一度だけ呼ばれ、返された関数を各 Metadata に適用します。
+func enrichMetadata(ctx context.Context, input <-chan MetadataInProcess,
+	load func() (func(meta *Metadata), error)) <-chan MetadataInProcess {
+
+	return tryEnrichMetadata(ctx, input, "", func() (func(meta *Metadata) error, error) {
+		apply, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return func(meta *Metadata) error {
+			apply(meta)
+			return nil
+		}, nil
+	})
+}
+
+// tryEnrichMetadata は、テーブルごとに失敗することがある enrichMetadata です。
+// apply のエラーは、ステージ stage の Warning にして Metadata とともに流し、
+// テーブルは出力します。load のエラーを流した後は、input の Metadata をそのまま流します。
+func tryEnrichMetadata(ctx context.Context, input <-chan MetadataInProcess, stage string,
+	load func() (func(meta *Metadata) error, error)) <-chan MetadataInProcess {
+
+	output := make(chan MetadataInProcess)
+	go func() {
+		defer close(output)
+
+		apply, err := load()
+		if err != nil && !send(ctx, output, MetadataInProcess{Err: err}) {
+			return
+		}
+
+		// 上流のステージは ctx の取消で output を閉じるため、range で待ちます
+		for mip := range input {
+			if apply != nil && mip.Data.FormalName != "" {
+				if err := apply(&mip.Data); err != nil {
+					mip.Warnings = append(mip.Warnings, Warning{
+						Stage: stage, Object: mip.Data.FormalName, Message: err.Error()})
+				}
+			}
+			if !send(ctx, output, mip) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// schemaBuffer は、出力の順番を待つスキーマごとにためておく MetadataInProcess の数です。
+const schemaBuffer = 64
+
+// extractSchemas は、対象スキーマごとに pipeline を Config.Parallelism 個まで並行して実行し、
+// 結果を Config.TargetSchema の順に流します。先に進んだスキーマは schemaBuffer 個まで
+// ためると出力の順番を待つため、メモリの使用量は並列数に比例します。
+// pipeline には、対象スキーマを 1 つにした Config の複製を渡します。pipeline は、ほかの
+// スキーマのテーブルを参照するビューや別名のカラムを、流れではなくカタログから読みます。
+func extractSchemas(ctx context.Context, config *Config,
+	pipeline func(ctx context.Context, config *Config) <-chan MetadataInProcess) <-chan MetadataInProcess {
+
+	streams := make([]chan MetadataInProcess, len(config.TargetSchema))
+	for i := range streams {
+		streams[i] = make(chan MetadataInProcess, schemaBuffer)
+	}
+	go func() {
+		// 出力中のスキーマは先に始めているため、後のスキーマが枠を埋めても止まりません
+		slots := make(chan struct{}, config.parallelism())
+		for i, schema := range config.TargetSchema {
+			select {
+			case <-ctx.Done():
+				for _, stream := range streams[i:] {
+					close(stream)
+				}
+				return
+			case slots <- struct{}{}:
+			}
+			schemaConfig := *config
+			schemaConfig.TargetSchema = []string{schema}
+			go func(schema string, stream chan<- MetadataInProcess) {
+				defer func() { <-slots }()
+				defer close(stream)
+				start := time.Now()
+				slog.DebugContext(ctx, "schema started", "schema", schema)
+				objects, errs := 0, 0
+				for mip := range pipeline(ctx, &schemaConfig) {
+					if mip.Data.FormalName != "" {
+						objects++
+					}
+					if mip.Err != nil {
+						errs++
+						mip.Err = schemaConfig.extractError(mip.Err)
+					}
+					if !send(ctx, stream, mip) {
+						return
+					}
+				}
+				slog.InfoContext(ctx, "schema extracted", "schema", schema,
+					"objects", objects, "errors", errs, "duration", time.Since(start))
+			}(schema, streams[i])
+		}
+	}()
+
+	output := make(chan MetadataInProcess)
+	go func() {
+		defer close(output)
+		for i, stream := range streams {
+			config.ProgressCounter.setSchema(config.TargetSchema[i])
+			for mip := range stream {
+				if !send(ctx, output, mip) {
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// addProgressTotal は、Config.ProgressCounter が nil でない場合に、テーブルの数を
+// TABLES カラムに返す照会 stmt を実行し、進み具合の分母に加えます。
+// 数えられない場合は、残り時間の見込みを示さずに抽出を続けます。
+func addProgressTotal(ctx context.Context, pool *sql.DB, config *Config, stmt string) {
+	if config.ProgressCounter == nil {
+		return
+	}
+	err := QueryRows(ctx, pool, stmt, func(m map[string]string) error {
+		n, err := strconv.ParseInt(m["TABLES"], 10, 64)
+		if err != nil {
+			return err
+		}
+		config.ProgressCounter.addTotal(n)
+		return nil
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "cannot count tables", "error", err)
+	}
+}
+
+// appendMetadata は、input の Metadata をすべて流した後に、load で作った
+// Metadata を流すステージです。テーブル以外のオブジェクトの抽出に使います。
+func appendMetadata(ctx context.Context, input <-chan MetadataInProcess,
+	load func() ([]Metadata, error)) <-chan MetadataInProcess {
+
+	output := make(chan MetadataInProcess)
+	go func() {
+		defer close(output)
+
+		// 上流のステージは ctx の取消で output を閉じるため、range で待ちます
+		for mip := range input {
+			if !send(ctx, output, mip) {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		list, err := load()
+		if err != nil {
+			send(ctx, output, MetadataInProcess{Err: err})
+			return
+		}
+		for _, meta := range list {
+			if !send(ctx, output, MetadataInProcess{Data: meta}) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// columnRow は、カラムの照会結果の 1 行から作った Column と、結合に使うキーです。
+type columnRow struct {
+	key        string
+	formalName string
+	column     Column
+}
+
+// joinKey は、テーブルとカラムを結合するキーを返します。カタログによって
+// スキーマの大文字小文字や後ろの空白が異なることがあるため、スキーマを正規化します。
+func joinKey(formalName string) string {
+	schema, name, ok := strings.Cut(formalName, ".")
+	if !ok {
+		return formalName
+	}
+	return strings.ToUpper(strings.TrimSpace(schema)) + "." + name
 }
 
-// writeCSV は、メタデータを Zip ファイルに出力します。
-func writeCSV(ctx context.Context,
-	input <-chan MetadataInProcess, out io.Writer) error {
+// binaryOrder は、スキーマ、名前の順に、結合キーを文字コードの順で比べます。
+// Db2 for LUW と Db2 for z/OS のカタログ(Unicode)の ORDER BY の順です。
+func binaryOrder(a, b string) int {
+	return compareKeys(a, b, func(r rune) int { return int(r) })
+}
+
+// ebcdicOrder は、スキーマ、名前の順に、結合キーを EBCDIC(CCSID 37)の順で比べます。
+// Db2 for i のカタログの ORDER BY の順です。
+func ebcdicOrder(a, b string) int {
+	return compareKeys(a, b, func(r rune) int {
+		if r >= ' ' && r <= '~' {
+			return int(ebcdicWeights[r-' '])
+		}
+		return 0x100 + int(r)
+	})
+}
+
+// ebcdicWeights は、ASCII の表示可能な文字(空白から ~ まで)の CCSID 37 のコードです。
+var ebcdicWeights = [...]byte{
+	0x40, 0x5A, 0x7F, 0x7B, 0x5B, 0x6C, 0x50, 0x7D, 0x4D, 0x5D, 0x5C, 0x4E, 0x6B, 0x60, 0x4B, 0x61, // ' '-'/'
+	0xF0, 0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8, 0xF9, 0x7A, 0x5E, 0x4C, 0x7E, 0x6E, 0x6F, // '0'-'?'
+	0x7C, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0xD1, 0xD2, 0xD3, 0xD4, 0xD5, 0xD6, // '@'-'O'
+	0xD7, 0xD8, 0xD9, 0xE2, 0xE3, 0xE4, 0xE5, 0xE6, 0xE7, 0xE8, 0xE9, 0xBA, 0xE0, 0xBB, 0xB0, 0x6D, // 'P'-'_'
+	0x79, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89, 0x91, 0x92, 0x93, 0x94, 0x95, 0x96, // '`'-'o'
+	0x97, 0x98, 0x99, 0xA2, 0xA3, 0xA4, 0xA5, 0xA6, 0xA7, 0xA8, 0xA9, 0xC0, 0x4F, 0xD0, 0xA1, // 'p'-'~'
+}
+
+// compareKeys は、結合キーをスキーマ、名前の順に、文字の重み weight で比べます。
+// Db2 と同じく、短い方は後ろに空白を補って比べます。
+func compareKeys(a, b string, weight func(r rune) int) int {
+	schemaA, nameA, _ := strings.Cut(a, ".")
+	schemaB, nameB, _ := strings.Cut(b, ".")
+	if c := compareIdentifiers(schemaA, schemaB, weight); c != 0 {
+		return c
+	}
+	return compareIdentifiers(nameA, nameB, weight)
+}
+
+// compareIdentifiers は、2 つの名前を文字の重み weight で比べます。
+func compareIdentifiers(a, b string, weight func(r rune) int) int {
+	ra, rb := []rune(a), []rune(b)
+	for i := 0; i < len(ra) || i < len(rb); i++ {
+		wa, wb := weight(' '), weight(' ')
+		if i < len(ra) {
+			wa = weight(ra[i])
+		}
+		if i < len(rb) {
+			wb = weight(rb[i])
+		}
+		if wa != wb {
+			if wa < wb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// joinColumns は、input の Metadata に、カラムの照会結果を正式名で結合するステージです。
+// open は照会を開始し、1 行ずつ Column と正式名を返す next と、照会を閉じる close を
+// 返します。next は、照会結果の終わりで io.EOF を返します。order は、照会の ORDER BY と
+// 同じ順で 2 つの結合キーを比べます。
+//
+// テーブルとカラムは同じ順に並んでいるため、テーブルより後に並ぶ行を読んだところで
+// そのテーブルを流し、読んだ行は次のテーブルに使います。保留するのは、テーブルより
+// 前に並ぶ行(結合できないカラム)だけです。
+// カラムのないテーブルと、テーブルに結合できなかったカラムは Warning にします。
+// 照会のエラーを流した後は、残りのテーブルをカラムなしで流します。
+func joinColumns(ctx context.Context, input <-chan MetadataInProcess, stage string,
+	order func(a, b string) int,
+	open func() (next func() (*Column, string, error), close func() error, err error),
+) <-chan MetadataInProcess {
+
+	output := make(chan MetadataInProcess)
+	go func() {
+		defer close(output)
+
+		var row *columnRow
+		eof := false
+		next, closeRows, err := open()
+		if err != nil {
+			if !send(ctx, output, MetadataInProcess{Err: err}) {
+				return
+			}
+			eof = true
+		} else {
+			defer closeRows()
+		}
+		// read は、保留していない次の行を row に読み込みます。照会のエラーを流せなかった
+		// 場合は偽を返します。
+		read := func() bool {
+			if row != nil || eof {
+				return true
+			}
+			col, formalName, err := next()
+			if err != nil {
+				eof = true
+				return err == io.EOF || send(ctx, output, MetadataInProcess{Err: err})
+			}
+			row = &columnRow{key: joinKey(formalName), formalName: formalName, column: *col}
+			return true
+		}
+		pending := make(map[string][]columnRow)
+		keys := []string{}
+		emitted := make(map[string]bool)
+		hold := func(r columnRow) {
+			if _, ok := pending[r.key]; !ok {
+				keys = append(keys, r.key)
+			}
+			pending[r.key] = append(pending[r.key], r)
+		}
+
+		// 上流のステージは ctx の取消で output を閉じるため、range で待ちます
+		for mip := range input {
+			if mip.Data.FormalName != "" {
+				meta := &mip.Data
+				key := joinKey(meta.FormalName)
+				list := pending[key]
+				delete(pending, key)
+				// テーブルより後に並ぶ行まで読み進め、前に並ぶ行は保留します
+				for {
+					if !read() {
+						return
+					}
+					if row == nil {
+						break
+					}
+					c := order(row.key, key)
+					if c > 0 {
+						break
+					}
+					if c == 0 {
+						list = append(list, *row)
+					} else {
+						hold(*row)
+					}
+					row = nil
+				}
+				emitted[key] = true
+				for _, r := range list {
+					meta.Columns = append(meta.Columns, r.column)
+				}
+				sort.SliceStable(meta.Columns, func(i, j int) bool {
+					return meta.Columns[i].Order < meta.Columns[j].Order
+				})
+				if len(meta.Columns) == 0 {
+					mip.Warnings = append(mip.Warnings, Warning{
+						Stage: stage, Object: meta.FormalName, Message: "no columns found"})
+				}
+			}
+			if !send(ctx, output, mip) {
+				return
+			}
+		}
+
+		// 残りの行は、どのテーブルにも結合できないカラムです
+		for {
+			if !read() {
+				return
+			}
+			if row == nil {
+				break
+			}
+			hold(*row)
+			row = nil
+		}
+		warnings := []Warning{}
+		for _, key := range keys {
+			list, ok := pending[key]
+			if !ok {
+				continue
+			}
+			message := fmt.Sprintf("%d columns have no matching table", len(list))
+			if emitted[key] {
+				message = fmt.Sprintf("%d columns were found after the table", len(list))
+			}
+			warnings = append(warnings, Warning{Stage: stage, Object: list[0].formalName, Message: message})
+		}
+		if len(warnings) > 0 {
+			send(ctx, output, MetadataInProcess{Warnings: warnings})
+		}
+	}()
+	return output
+}
+
+// routineList は、ルーチンの Metadata を特定名の順に集めます。
+type routineList struct {
+	list  []Metadata
+	index map[string]int
+}
+
+// newRoutineList は、routineList を作ります。
+func newRoutineList() *routineList {
+	return &routineList{index: make(map[string]int)}
+}
 
+// add は、スキーマのルーチンを追加します。meta.Routine.SpecificName が必要です。
+func (l *routineList) add(schema string, meta Metadata) {
+	l.index[schema+"."+meta.Routine.SpecificName] = len(l.list)
+	l.list = append(l.list, meta)
+}
+
+// addParameter は、スキーマと特定名が一致するルーチンにパラメーターを追加します。
+// 追加していないルーチンのパラメーターは無視します。Column.Order は、追加した順の
+// 1 から始まる番号で、戻り値はパラメーターの後に追加します。
+func (l *routineList) addParameter(schema string, specificName string, col Column) {
+	i, ok := l.index[schema+"."+specificName]
+	if !ok {
+		return
+	}
+	meta := &l.list[i]
+	col.Order = len(meta.Columns) + 1
+	meta.Columns = append(meta.Columns, col)
+}
+
+// Metadata は、集めたルーチンの Metadata を返します。多重定義されたルーチンは
+// 正式名が重複するため、スキーマ.特定名 を正式名にします。
+func (l *routineList) Metadata() []Metadata {
+	count := make(map[string]int)
+	for _, meta := range l.list {
+		count[meta.FormalName]++
+	}
+	for i := range l.list {
+		meta := &l.list[i]
+		if count[meta.FormalName] > 1 {
+			schema := strings.TrimSuffix(meta.FormalName, "."+meta.Name)
+			meta.FormalName = schema + "." + meta.Routine.SpecificName
+		}
+	}
+	return l.list
+}
+
+// addConstraint は、制約のカラムを 1 行分追加します。
+// 行は、テーブル、制約名、キーの順に並んでいる必要があります。
+func addConstraint(constraints map[string][]Constraint, formalName string,
+	name string, typ int, column string, refTable string, refColumn string) {
+
+	list := constraints[formalName]
+	if len(list) == 0 || list[len(list)-1].Name != name {
+		list = append(list, Constraint{Name: name, Type: typ, RefTable: refTable})
+	}
+	c := &list[len(list)-1]
+	c.Columns = append(c.Columns, column)
+	if refColumn != "" {
+		c.RefColumns = append(c.RefColumns, refColumn)
+	}
+	constraints[formalName] = list
+}
+
+// addIndexColumn は、テーブルの正式名ごとの索引の一覧に、索引のカラムを追加します。
+// 行は、テーブル、索引、キーの順に並んでいる必要があります。include が真の
+// カラムは、キーではなく INCLUDE カラムとして追加します。
+func addIndexColumn(indexes map[string][]Index, formalName string, index Index,
+	column string, order string, include bool) {
+
+	list := indexes[formalName]
+	last := len(list) - 1
+	if last < 0 || list[last].Schema != index.Schema || list[last].Name != index.Name {
+		list = append(list, index)
+		last++
+	}
+	idx := &list[last]
+	if include {
+		idx.Include = append(idx.Include, column)
+	} else {
+		idx.Columns = append(idx.Columns, IndexColumn{Name: column, Order: order})
+	}
+	indexes[formalName] = list
+}
+
+// MetadataWriter は、Metadata を出力形式に変換して書き出します。
+type MetadataWriter interface {
+	// Write は、Metadata を 1 件書き出します。
+	Write(m *Metadata) error
+	// Close は、出力を完了します。io.Writer は閉じません。
+	Close() error
+}
+
+// NewMetadataWriter は、out に書き出す MetadataWriter を作ります。
+type NewMetadataWriter func(out io.Writer, config *Config) MetadataWriter
+
+// DefaultFormat は、Config.Format が空の場合の出力形式です。
+const DefaultFormat = "mashu"
+
+var (
+	writersMu sync.RWMutex
+	writers   = make(map[string]NewMetadataWriter)
+)
+
+// registerWriter は、MetadataWriter を出力形式名で登録します。
+func registerWriter(name string, newWriter NewMetadataWriter) {
+	writersMu.Lock()
+	defer writersMu.Unlock()
+	// nil チェック、二重登録チェックは自パッケージ内のみのため省略
+	writers[name] = newWriter
+}
+
+// GetWriter は、出力形式名に対応した NewMetadataWriter を返します。
+func GetWriter(name string) NewMetadataWriter {
+	if name == "" {
+		name = DefaultFormat
+	}
+	writersMu.RLock()
+	newWriter, ok := writers[name]
+	writersMu.RUnlock()
+	if ok {
+		return newWriter
+	}
+	return nil
+}
+
+// WriterNames は、登録されている出力形式名をソートして返します。
+func WriterNames() []string {
+	writersMu.RLock()
+	defer writersMu.RUnlock()
+	names := make([]string, 0, len(writers))
+	for name := range writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeMetadata は、メタデータを Config.Format の形式で出力します。
+// エラーは Config.OnError の方針で扱い、すべてのエラーをまとめて返します。
+//   - fail: 最初のエラーで出力を止めます。出力を完了しないため、途中までの出力が残ります。
+//   - skip-table: テーブルのエラーはそのテーブルを出力せずに続け、カタログ全体の
+//     照会のエラーで出力を止めます。
+//   - keep-going: すべてのエラーで続け、エラーのあったテーブルも抽出できた情報で出力します。
+func writeMetadata(ctx context.Context,
+	input <-chan MetadataInProcess, out io.Writer, config *Config) error {
+
+	newWriter := GetWriter(config.Format)
+	if newWriter == nil {
+		return fmt.Errorf("unknown format: %s", config.Format)
+	}
+	w := newWriter(out, config)
+	errs := []error{}
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return errors.Join(append(errs, ctx.Err())...)
 		case m, ok := <-input:
 			if !ok {
-				return nil
+				return errors.Join(append(errs, w.Close())...)
+			}
+			for _, warning := range m.Warnings {
+				config.warn(warning)
+			}
+			config.ProgressCounter.tableDone(&m.Data)
+			if m.Err != nil {
+				errs = append(errs, config.extractError(m.Err))
+				slog.ErrorContext(ctx, "extract failed", "error", m.Err, "onError", config.OnError)
+				switch {
+				case config.OnError == OnErrorKeepGoing:
+				case config.OnError == OnErrorSkipTable && m.Data.FormalName != "":
+					continue
+				default:
+					return errors.Join(errs...)
+				}
+			}
+			if m.Data.FormalName == "" {
+				continue
 			}
-			_, err := out.Write([]byte(m.Data.ToCSVString()))
+			err := w.Write(&m.Data)
 			if err != nil {
-				return err
+				return errors.Join(append(errs, err)...)
 			}
 		}
 	}
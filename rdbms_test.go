@@ -0,0 +1,299 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJoinColumns(t *testing.T) {
+	// row は、テーブルの正式名とカラム名の組です。
+	type row struct{ table, column string }
+	tests := []struct {
+		name     string
+		tables   []string
+		rows     []row
+		want     map[string][]string
+		warnings []Warning
+	}{
+		{"in order",
+			[]string{"S.A", "S.B"},
+			[]row{{"S.A", "A1"}, {"S.A", "A2"}, {"S.B", "B1"}},
+			map[string][]string{"S.A": {"A1", "A2"}, "S.B": {"B1"}},
+			nil},
+		{"empty table",
+			[]string{"S.A", "S.B", "S.C"},
+			[]row{{"S.A", "A1"}, {"S.C", "C1"}},
+			map[string][]string{"S.A": {"A1"}, "S.B": nil, "S.C": {"C1"}},
+			[]Warning{{"columns", "S.B", "no columns found"}}},
+		{"out of order rows",
+			[]string{"S.A", "S.B", "S.C"},
+			[]row{{"S.C", "C1"}, {"S.B", "B1"}, {"S.A", "A1"}, {"S.A", "A2"}},
+			map[string][]string{"S.A": nil, "S.B": nil, "S.C": {"C1"}},
+			[]Warning{
+				{"columns", "S.A", "no columns found"},
+				{"columns", "S.B", "no columns found"},
+				{"columns", "S.B", "1 columns were found after the table"},
+				{"columns", "S.A", "2 columns were found after the table"},
+			}},
+		{"schema with blank and lower case",
+			[]string{"S.A", "S.B"},
+			[]row{{"S  .A", "A1"}, {"s.B", "B1"}},
+			map[string][]string{"S.A": {"A1"}, "S.B": {"B1"}},
+			nil},
+		{"orphan and late columns",
+			[]string{"S.A", "S.B"},
+			[]row{{"S.A", "A1"}, {"S.B", "B1"}, {"S.X", "X1"}, {"S.A", "A2"}, {"S.X", "X2"}},
+			map[string][]string{"S.A": {"A1"}, "S.B": {"B1"}},
+			[]Warning{
+				{"columns", "S.X", "2 columns have no matching table"},
+				{"columns", "S.A", "1 columns were found after the table"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			input := make(chan MetadataInProcess)
+			go func() {
+				defer close(input)
+				for _, name := range tt.tables {
+					input <- MetadataInProcess{Data: Metadata{FormalName: name}}
+				}
+			}()
+			i := 0
+			output := joinColumns(ctx, input, "columns", binaryOrder,
+				func() (func() (*Column, string, error), func() error, error) {
+					return func() (*Column, string, error) {
+						if i == len(tt.rows) {
+							return nil, "", io.EOF
+						}
+						r := tt.rows[i]
+						i++
+						return &Column{Name: r.column, Order: i}, r.table, nil
+					}, func() error { return nil }, nil
+				})
+
+			got := map[string][]string{}
+			var warnings []Warning
+			for mip := range output {
+				if mip.Err != nil {
+					t.Fatalf("joinColumns() error :%s", mip.Err)
+				}
+				warnings = append(warnings, mip.Warnings...)
+				if mip.Data.FormalName == "" {
+					continue
+				}
+				var names []string
+				for _, c := range mip.Data.Columns {
+					names = append(names, c.Name)
+				}
+				got[mip.Data.FormalName] = names
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("joinColumns() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("joinColumns() warnings = %v, want %v", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestJoinColumnsReadAhead(t *testing.T) {
+	// 先頭のカラムのないテーブルの後に、カラムが 1 つずつのテーブルが続きます
+	tables := []string{"S.E"}
+	for i := 1; i <= 20; i++ {
+		tables = append(tables, fmt.Sprintf("S.T%02d", i))
+	}
+	ctx := context.Background()
+	input := make(chan MetadataInProcess)
+	go func() {
+		defer close(input)
+		for _, name := range tables {
+			input <- MetadataInProcess{Data: Metadata{FormalName: name}}
+		}
+	}()
+	var read atomic.Int32
+	output := joinColumns(ctx, input, "columns", binaryOrder,
+		func() (func() (*Column, string, error), func() error, error) {
+			return func() (*Column, string, error) {
+				n := int(read.Add(1))
+				if n >= len(tables) {
+					return nil, "", io.EOF
+				}
+				return &Column{Name: "C", Order: 1}, tables[n], nil
+			}, func() error { return nil }, nil
+		})
+
+	i := 0
+	for mip := range output {
+		if mip.Data.FormalName == "" {
+			t.Errorf("joinColumns() warnings = %v, want none", mip.Warnings)
+			continue
+		}
+		// 流したテーブルの次のテーブルの行まで読み、さらに 1 行先読みする場合があります
+		if n := int(read.Load()); n > i+2 {
+			t.Errorf("joinColumns() read %d rows before %s, want at most %d", n, mip.Data.FormalName, i+2)
+		}
+		i++
+	}
+	if i != len(tables) {
+		t.Errorf("joinColumns() emitted %d tables, want %d", i, len(tables))
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		binary int
+		ebcdic int
+	}{
+		{"S.A", "S.A", 0, 0},
+		{"S.A", "S.B", -1, -1},
+		{"S.A1", "S.AB", -1, 1},
+		{"S.a", "S.A", 1, -1},
+		{"S.A", "S.A_B", -1, -1},
+		{"S.A", "S.A ", 0, 0},
+		{"A.Z", "A1.A", -1, -1},
+	}
+	for _, tt := range tests {
+		if got := binaryOrder(tt.a, tt.b); got != tt.binary {
+			t.Errorf("binaryOrder(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.binary)
+		}
+		if got := ebcdicOrder(tt.a, tt.b); got != tt.ebcdic {
+			t.Errorf("ebcdicOrder(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.ebcdic)
+		}
+	}
+}
+
+func TestExtractError(t *testing.T) {
+	err := queryError("SELECT A\n\t\tFROM S.T", errors.New("SQL0204N"))
+	want := "query SELECT A FROM S.T: SQL0204N"
+	if got := err.Error(); got != want {
+		t.Errorf("queryError() = %s, want %s", got, want)
+	}
+	err = &ExtractError{Schema: "S", Table: "T", Query: "SELECT A FROM S.T", Err: errors.New("SQL0204N")}
+	want = "schema S: table T: query SELECT A FROM S.T: SQL0204N"
+	if got := err.Error(); got != want {
+		t.Errorf("ExtractError.Error() = %s, want %s", got, want)
+	}
+	config := &Config{TargetSchema: []string{"S1", "S2"}}
+	want = "schema S1, S2: SQL0204N"
+	if got := config.extractError(errors.New("SQL0204N")).Error(); got != want {
+		t.Errorf("extractError() = %s, want %s", got, want)
+	}
+}
+
+func TestWriteMetadataOnError(t *testing.T) {
+	tableErr := &ExtractError{Schema: "S", Table: "B", Err: errors.New("table")}
+	catalogErr := &ExtractError{Query: "SELECT 1", Err: errors.New("catalog")}
+	input := []MetadataInProcess{
+		{Data: Metadata{Name: "A", FormalName: "S.A"}},
+		{Data: Metadata{Name: "B", FormalName: "S.B"}, Err: tableErr},
+		{Data: Metadata{Name: "C", FormalName: "S.C"}},
+		{Err: catalogErr},
+		{Data: Metadata{Name: "D", FormalName: "S.D"}},
+	}
+	tests := []struct {
+		onError string
+		want    []string
+		errs    []error
+	}{
+		{OnErrorFail, []string{"S.A"}, []error{tableErr}},
+		{OnErrorSkipTable, []string{"S.A", "S.C"}, []error{tableErr, catalogErr}},
+		{OnErrorKeepGoing, []string{"S.A", "S.B", "S.C", "S.D"}, []error{tableErr, catalogErr}},
+	}
+	for _, tt := range tests {
+		ch := make(chan MetadataInProcess, len(input))
+		for _, mip := range input {
+			ch <- mip
+		}
+		close(ch)
+		config := &Config{Format: "jsonl", TargetSchema: []string{"S"}, OnError: tt.onError}
+		output := &bytes.Buffer{}
+		err := writeMetadata(context.Background(), ch, output, config)
+
+		var got []string
+		dec := json.NewDecoder(output)
+		for dec.More() {
+			var m Metadata
+			if err := dec.Decode(&m); err != nil {
+				t.Fatalf("json.Decoder.Decode() error :%s", err)
+			}
+			got = append(got, m.FormalName)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: writeMetadata() wrote %v, want %v", tt.onError, got, tt.want)
+		}
+		for _, e := range tt.errs {
+			if !errors.Is(err, e) {
+				t.Errorf("%s: writeMetadata() error = %v, want %v", tt.onError, err, e)
+			}
+		}
+		if want := "schema S: query SELECT 1: catalog"; len(tt.errs) > 1 && !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: writeMetadata() error = %v, want %s", tt.onError, err, want)
+		}
+	}
+}
+
+func TestExtractSchemas(t *testing.T) {
+	config := &Config{TargetSchema: []string{"S1", "S2", "S3", "S4", "S5"}, Parallelism: 2}
+	var running, peak int32
+	output := extractSchemas(context.Background(), config,
+		func(ctx context.Context, config *Config) <-chan MetadataInProcess {
+			schema := config.TargetSchema[0]
+			output := make(chan MetadataInProcess)
+			go func() {
+				defer close(output)
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				// 後のスキーマほど早く終わるようにします
+				time.Sleep(time.Duration('5'-schema[1]) * time.Millisecond)
+				for _, name := range []string{"A", "B"} {
+					output <- MetadataInProcess{Data: Metadata{Name: name, FormalName: schema + "." + name}}
+				}
+				output <- MetadataInProcess{Err: errors.New("failed")}
+			}()
+			return output
+		})
+
+	var got, schemas []string
+	for mip := range output {
+		if mip.Err != nil {
+			var e *ExtractError
+			if errors.As(mip.Err, &e) {
+				schemas = append(schemas, e.Schema)
+			}
+			continue
+		}
+		got = append(got, mip.Data.FormalName)
+	}
+	want := []string{"S1.A", "S1.B", "S2.A", "S2.B", "S3.A", "S3.B", "S4.A", "S4.B", "S5.A", "S5.B"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractSchemas() = %v, want %v", got, want)
+	}
+	if want := config.TargetSchema; !reflect.DeepEqual(schemas, want) {
+		t.Errorf("extractSchemas() error schemas = %v, want %v", schemas, want)
+	}
+	if peak > 2 {
+		t.Errorf("extractSchemas() ran %d pipelines at once, want at most 2", peak)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// 接続情報を上書きする環境変数
+const (
+	EnvHostname = "MASHU_DB2_HOSTNAME"
+	EnvUserID   = "MASHU_DB2_USERID"
+	EnvPassword = "MASHU_DB2_PASSWORD"
+)
+
+// Secrets は、設定ファイルとは別に管理する接続情報です。
+type Secrets struct {
+	Hostname string `json:"hostname"`
+	UserID   string `json:"userid"`
+	Password string `json:"password"`
+}
+
+// ResolveSecrets は、接続情報を次の順で上書きします。
+//  1. Config.SecretsFile の JSON ファイル
+//  2. 環境変数 MASHU_DB2_HOSTNAME, MASHU_DB2_USERID, MASHU_DB2_PASSWORD
+//  3. パスワードが空のままなら prompt (nil の場合は問い合わせません)
+func (c *Config) ResolveSecrets(prompt func() (string, error)) error {
+	if c.fileValues == nil {
+		c.fileValues = &Secrets{
+			Hostname: c.Hostname,
+			UserID:   c.UserID,
+			Password: c.Password,
+		}
+	}
+
+	if c.SecretsFile != "" {
+		data, err := os.ReadFile(c.SecretsFile)
+		if err != nil {
+			return err
+		}
+		var s Secrets
+		err = json.Unmarshal(data, &s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.SecretsFile, err)
+		}
+		c.override(&s)
+	}
+
+	c.override(&Secrets{
+		Hostname: os.Getenv(EnvHostname),
+		UserID:   os.Getenv(EnvUserID),
+		Password: os.Getenv(EnvPassword),
+	})
+
+	if c.Password == "" && prompt != nil {
+		password, err := prompt()
+		if err != nil {
+			return err
+		}
+		c.Password = password
+	}
+	return nil
+}
+
+// override は、空でない値で接続情報を上書きします。
+func (c *Config) override(s *Secrets) {
+	if s.Hostname != "" {
+		c.Hostname = s.Hostname
+	}
+	if s.UserID != "" {
+		c.UserID = s.UserID
+	}
+	if s.Password != "" {
+		c.Password = s.Password
+	}
+}
+
+// Redact は、エラーメッセージから DSN のパスワードとパスワードそのものを伏せます。
+func (c *Config) Redact(err error) string {
+	return c.redact(err.Error())
+}
+
+// redact は、文字列から DSN のパスワードとパスワードそのものを伏せます。
+func (c *Config) redact(str string) string {
+	str = RedactDSN(str)
+	// 短いパスワードは誤って他の文字列を伏せてしまうため対象外
+	if len(c.Password) >= 4 {
+		str = strings.ReplaceAll(str, c.Password, redacted)
+	}
+	return str
+}
+
+const redacted = "****"
+
+var dsnSecret = regexp.MustCompile(`(?i)\b([A-Z]*PASSWORD|PWD)=(\{(?:[^}]|\}\})*\}|[^;]*)`)
+
+// RedactDSN は、DSN 形式の文字列に含まれるパスワードを伏せます。{} で囲んだ値は、
+// }} を値の中の } として扱います。
+func RedactDSN(str string) string {
+	return dsnSecret.ReplaceAllString(str, "${1}="+redacted)
+}
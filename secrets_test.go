@@ -0,0 +1,111 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets.json")
+	err := os.WriteFile(secrets,
+		[]byte(`{"userid": "fileuser", "password": "filepass", "hostname": "filehost"}`), 0600)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error :%s", err)
+	}
+	t.Setenv(EnvHostname, "")
+	t.Setenv(EnvUserID, "envuser")
+	t.Setenv(EnvPassword, "")
+
+	config := testConfig()
+	config.Password = ""
+	config.SecretsFile = secrets
+	err = config.ResolveSecrets(func() (string, error) {
+		t.Errorf("prompt is called although secretsFile has password")
+		return "", nil
+	})
+	if err != nil {
+		t.Fatalf("ResolveSecrets() error :%s", err)
+	}
+	if config.Hostname != "filehost" || config.UserID != "envuser" || config.Password != "filepass" {
+		t.Errorf("ResolveSecrets() = %s, %s, %s", config.Hostname, config.UserID, config.Password)
+	}
+
+	path := filepath.Join(dir, "config.json")
+	if err := config.Save(path); err != nil {
+		t.Fatalf("Config.Save() error :%s", err)
+	}
+	saved, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error :%s", err)
+	}
+	if saved.Password != "" || saved.UserID != "db2inst1" || saved.Hostname != "localhost" {
+		t.Errorf("Save() wrote %s, %s, %s", saved.Hostname, saved.UserID, saved.Password)
+	}
+}
+
+func TestResolveSecretsPrompt(t *testing.T) {
+	t.Setenv(EnvPassword, "")
+	config := testConfig()
+	config.Password = ""
+	err := config.ResolveSecrets(func() (string, error) { return "typed", nil })
+	if err != nil {
+		t.Fatalf("ResolveSecrets() error :%s", err)
+	}
+	if config.Password != "typed" {
+		t.Errorf("Password = %q, want %q", config.Password, "typed")
+	}
+
+	t.Setenv(EnvPassword, "fromenv")
+	config = testConfig()
+	err = config.ResolveSecrets(func() (string, error) { return "typed", nil })
+	if err != nil {
+		t.Fatalf("ResolveSecrets() error :%s", err)
+	}
+	if config.Password != "fromenv" {
+		t.Errorf("Password = %q, want %q", config.Password, "fromenv")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	config := testConfig()
+	config.Password = "s3cr;et"
+	dsn := config.Db2DSN()
+
+	for _, str := range []string{
+		fmt.Sprintf("%v", dsn),
+		fmt.Sprintf("%#v", dsn),
+		RedactDSN("Security=SSL;SSLClientKeystoreDBPassword=stash;pwd=abc"),
+		config.Redact(errors.New("login failed for s3cr;et")),
+	} {
+		for _, secret := range []string{"s3cr", "stash", "abc"} {
+			if strings.Contains(str, secret) {
+				t.Errorf("%q is not redacted in %q", secret, str)
+			}
+		}
+		if !strings.Contains(str, redacted) {
+			t.Errorf("%q has no redaction mark", str)
+		}
+	}
+}
+
+func TestRedactBraces(t *testing.T) {
+	config := testConfig()
+	config.Password = "ab}c;x"
+	want := "HOSTNAME=localhost;DATABASE=LUW;PORT=50000;UID=db2inst1;PWD=" + redacted
+	if got := config.Db2DSN().String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	want = "connect PWD=" + redacted + ";UID=db2inst1: SQL30082N"
+	if got := RedactDSN("connect PWD={ab}}c;x};UID=db2inst1: SQL30082N"); got != want {
+		t.Errorf("RedactDSN() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+CREATE TABLE "DB2INST1"."DEPARTMENT" (
+  "DEPTNO" CHARACTER(3) NOT NULL,
+  "DEPTNAME" VARCHAR(36) NOT NULL,
+  "MGRNO" CHARACTER(6),
+  "ADMRDEPT" CHARACTER(3) NOT NULL DEFAULT 'A00',
+  "LOCATION" CHARACTER(16),
+  CONSTRAINT "FK_DEPT_ADMR" FOREIGN KEY ("ADMRDEPT") REFERENCES "DB2INST1"."DEPARTMENT" ("DEPTNO"),
+  CONSTRAINT "FK_DEPT_MGR" FOREIGN KEY ("MGRNO") REFERENCES "DB2INST1"."EMPLOYEE" ("EMPNO"),
+  CONSTRAINT "PK_DEPARTMENT" PRIMARY KEY ("DEPTNO")
+);
+COMMENT ON TABLE "DB2INST1"."DEPARTMENT" IS '部門';
+COMMENT ON COLUMN "DB2INST1"."DEPARTMENT"."DEPTNO" IS '部門番号';
+COMMENT ON COLUMN "DB2INST1"."DEPARTMENT"."DEPTNAME" IS '部門名';
+COMMENT ON COLUMN "DB2INST1"."DEPARTMENT"."MGRNO" IS '管理者番号';
+COMMENT ON COLUMN "DB2INST1"."DEPARTMENT"."ADMRDEPT" IS '管理部門';
+CREATE UNIQUE INDEX "DB2INST1"."XDEPT1" ON "DB2INST1"."DEPARTMENT" ("DEPTNO" ASC);
+
+CREATE TABLE "DB2INST1"."EMPLOYEE" (
+  "EMPNO" CHARACTER(6) NOT NULL,
+  "FIRSTNME" VARCHAR(12) NOT NULL,
+  "LASTNAME" VARCHAR(15) NOT NULL,
+  "WORKDEPT" CHARACTER(3),
+  "PHONENO" CHARACTER(4),
+  "HIREDATE" DATE DEFAULT CURRENT DATE,
+  "SALARY" DECIMAL(9,2),
+  "EMAIL" VARCHAR(254),
+  "BADGEID" CHARACTER(8) FOR BIT DATA,
+  "UPDATED_AT" TIMESTAMP(6) NOT NULL GENERATED ALWAYS AS ROW CHANGE TIMESTAMP,
+  "ANNUAL_SALARY" DECIMAL(11,2) GENERATED ALWAYS AS (SALARY * 12),
+  CONSTRAINT "FK_EMP_DEPT" FOREIGN KEY ("WORKDEPT") REFERENCES "DB2INST1"."DEPARTMENT" ("DEPTNO"),
+  CONSTRAINT "PK_EMPLOYEE" PRIMARY KEY ("EMPNO"),
+  CONSTRAINT "UK_EMP_EMAIL" UNIQUE ("EMAIL")
+);
+COMMENT ON TABLE "DB2INST1"."EMPLOYEE" IS '従業員。氏名, 所属部門, 給与を保持する';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."EMPNO" IS '社員番号';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."FIRSTNME" IS '名';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."LASTNAME" IS '姓';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."WORKDEPT" IS '所属部門';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."PHONENO" IS '内線番号';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."HIREDATE" IS '入社日';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."SALARY" IS '給与
+(月額, 円)';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."EMAIL" IS 'メールアドレス';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."BADGEID" IS '社員証ID';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."UPDATED_AT" IS '更新日時';
+COMMENT ON COLUMN "DB2INST1"."EMPLOYEE"."ANNUAL_SALARY" IS '年収';
+CREATE UNIQUE INDEX "DB2INST1"."XEMP1" ON "DB2INST1"."EMPLOYEE" ("EMPNO" ASC);
+CREATE INDEX "DB2INST1"."XEMP2" ON "DB2INST1"."EMPLOYEE" ("WORKDEPT" ASC) CLUSTER;
+CREATE UNIQUE INDEX "DB2INST1"."XEMP_NAME" ON "DB2INST1"."EMPLOYEE" ("LASTNAME" ASC, "FIRSTNME" DESC) INCLUDE ("EMPNO");
+
+CREATE VIEW DB2INST1.VEMP AS SELECT E.EMPNO, E.FIRSTNME || ' ' || E.LASTNAME AS NAME, DEPTNAME FROM DB2INST1.EMPLOYEE E LEFT JOIN DB2INST1.DEPARTMENT D ON E.WORKDEPT = D.DEPTNO;
+COMMENT ON TABLE "DB2INST1"."VEMP" IS '従業員"一覧"ビュー';
+
+CREATE FUNCTION DB2INST1.DEPT_NAME(EMPNUM CHAR(6)) RETURNS VARCHAR(36) SPECIFIC DEPT_NAME_BY_EMP RETURN SELECT D.DEPTNAME FROM DB2INST1.EMPLOYEE E JOIN DB2INST1.DEPARTMENT D ON E.WORKDEPT = D.DEPTNO WHERE E.EMPNO = EMPNUM;
+COMMENT ON SPECIFIC FUNCTION "DB2INST1"."DEPT_NAME_BY_EMP" IS '社員の部門名';
+
+CREATE FUNCTION DB2INST1.DEPT_NAME(DEPTNUM CHAR(3), ADMIN INTEGER) RETURNS VARCHAR(36) SPECIFIC DEPT_NAME_BY_NO RETURN SELECT DEPTNAME FROM DB2INST1.DEPARTMENT WHERE DEPTNO = DEPTNUM;
+COMMENT ON SPECIFIC FUNCTION "DB2INST1"."DEPT_NAME_BY_NO" IS '部門名';
+
+CREATE PROCEDURE DB2INST1.RAISE_SALARY(IN EMPNUM CHAR(6), IN RATE DECIMAL(5,2), OUT NEWSAL DECIMAL(9,2)) BEGIN UPDATE DB2INST1.EMPLOYEE SET SALARY = SALARY * RATE WHERE EMPNO = EMPNUM; SELECT SALARY INTO NEWSAL FROM DB2INST1.EMPLOYEE WHERE EMPNO = EMPNUM; END;
+COMMENT ON SPECIFIC PROCEDURE "DB2INST1"."SQL240401100000100" IS '昇給';
+
@@ -5,7 +5,14 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -15,12 +22,250 @@ type Config struct {
 	Database     string   `json:"database"`
 	Port         int      `json:"port"`
 	UserID       string   `json:"userid"`
-	Password     string   `json:"password"`
+	Password     string   `json:"password,omitempty"`
+	SecretsFile  string   `json:"secretsFile,omitempty"`
 	Lang         string   `json:"lang"`
 	Remarks      []string `json:"remarks"`
 	CSVFile      string   `json:"csvfile"`
+	Format       string   `json:"format,omitempty"`
+	Annotations  []string `json:"annotations,omitempty"`
 	SystemSchema string   `json:"systemSchema"`
 	TargetSchema []string `json:"targetSchema"`
+	ObjectTypes  []string `json:"objectTypes,omitempty"`
+	Statistics   bool     `json:"statistics,omitempty"`
+
+	// Profile は、テーブルのデータを標本にしてカラムのプロファイルを作るかどうかです。
+	// ProfileRows はテーブルごとの標本の行数の上限、ProfileTimeout は最初の標本から数えた
+	// プロファイル全体の秒数の上限で、0 の場合は既定値を使います。上限を過ぎた後の
+	// テーブルは、プロファイルを作らずに Warning とともに流します。ProfileValues は、
+	// プロファイルの標本の値(Min、Max、TopValues)とカラムの統計情報の値(High、Low)を
+	// 残すかどうかで、真でも分類されたカラムの値は残しません。
+	Profile        bool `json:"profile,omitempty"`
+	ProfileRows    int  `json:"profileRows,omitempty"`
+	ProfileTimeout int  `json:"profileTimeout,omitempty"`
+	ProfileValues  bool `json:"profileValues,omitempty"`
+
+	// Classify は、カラムを個人情報などに分類するかどうかです。ClassificationRules は、
+	// 組み込みの規則に加える分類の規則の JSON ファイルです。
+	Classify            bool   `json:"classify,omitempty"`
+	ClassificationRules string `json:"classificationRules,omitempty"`
+
+	// OnError は、抽出のエラーの扱いで、OnErrorFail、OnErrorSkipTable、OnErrorKeepGoing の
+	// いずれかです。空の場合は OnErrorFail です。
+	OnError string `json:"onError,omitempty"`
+
+	// Parallelism は、対象スキーマを並行して抽出する数です。0 の場合は 1 つずつ抽出します。
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// SSL 接続と CLI/ODBC キーワードは、Db2DSN の同名のフィールドを参照してください。
+	Security                    string            `json:"security,omitempty"`
+	SSLServerCertificate        string            `json:"sslServerCertificate,omitempty"`
+	SSLClientKeystoreDB         string            `json:"sslClientKeystoredb,omitempty"`
+	SSLClientKeystash           string            `json:"sslClientKeystash,omitempty"`
+	SSLClientHostnameValidation string            `json:"sslClientHostnameValidation,omitempty"`
+	Options                     map[string]string `json:"options,omitempty"`
+
+	// Progress は、抽出の進み具合を表示するかどうかです。
+	Progress bool `json:"progress,omitempty"`
+
+	// LogLevel は、trace、debug、info、warn、error のいずれかのログレベルで、trace では
+	// カタログの SQL 文を記録します。LogFormat は、text か json です。空の場合は
+	// info と text です。
+	LogLevel  string `json:"logLevel,omitempty"`
+	LogFormat string `json:"logFormat,omitempty"`
+
+	// WarningHandler は、抽出を続けられる問題を受け取ります。nil の場合は無視します。
+	WarningHandler func(w Warning) `json:"-"`
+	// ProgressCounter は、抽出の進み具合を数えます。nil の場合は数えません。
+	ProgressCounter *Progress `json:"-"`
+
+	// fileValues は、ResolveSecrets で上書きする前の設定ファイルの値です。
+	fileValues *Secrets
+}
+
+// Config.OnError の値
+const (
+	// OnErrorFail は、最初のエラーで抽出を止めます。
+	OnErrorFail = "fail"
+	// OnErrorSkipTable は、エラーのあったテーブルを出力せずに抽出を続けます。
+	OnErrorSkipTable = "skip-table"
+	// OnErrorKeepGoing は、エラーのあったテーブルも抽出できた情報で出力して続けます。
+	OnErrorKeepGoing = "keep-going"
+)
+
+// LoadConfig は、JSON 形式の設定ファイルを読み込みます。
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// warn は、WarningHandler に警告を渡します。
+func (c *Config) warn(w Warning) {
+	if c.WarningHandler != nil {
+		c.WarningHandler(w)
+	}
+}
+
+// extractError は、err の ExtractError のスキーマが空の場合に、対象スキーマを設定します。
+func (c *Config) extractError(err error) error {
+	var e *ExtractError
+	if !errors.As(err, &e) {
+		return &ExtractError{Schema: strings.Join(c.TargetSchema, ", "), Err: err}
+	}
+	if e.Schema == "" {
+		e.Schema = strings.Join(c.TargetSchema, ", ")
+	}
+	return err
+}
+
+// parallelism は、Config.Parallelism の既定値を補った並列数を返します。
+func (c *Config) parallelism() int {
+	if c.Parallelism <= 0 {
+		return 1
+	}
+	return c.Parallelism
+}
+
+// Save は、設定を JSON 形式でファイルに書き込みます。
+// パスワードは書き込まず、環境変数などで上書きした値は設定ファイルの値に戻します。
+func (c *Config) Save(path string) error {
+	saved := *c
+	saved.Password = ""
+	if c.fileValues != nil {
+		saved.UserID = c.fileValues.UserID
+		saved.Hostname = c.fileValues.Hostname
+	}
+	b, err := json.MarshalIndent(&saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0666)
+}
+
+// Validate は、設定値を検証し、問題をまとめたエラーを返します。
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Hostname == "" {
+		errs = append(errs, errors.New("hostname is empty"))
+	}
+	if c.Database == "" {
+		errs = append(errs, errors.New("database is empty"))
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if c.UserID == "" {
+		errs = append(errs, errors.New("userid is empty"))
+	}
+	for _, str := range c.Remarks {
+		if str != "Alias" && str != "Description" {
+			errs = append(errs, fmt.Errorf("remarks %q is not Alias or Description", str))
+		}
+	}
+	if !strings.EqualFold(c.SystemSchema, AutoSystemSchema) &&
+		GetExtractor(Db2Driver+"."+c.SystemSchema) == nil {
+		names := []string{}
+		for _, name := range ExtractorNames() {
+			names = append(names, strings.TrimPrefix(name, Db2Driver+"."))
+		}
+		errs = append(errs, fmt.Errorf("systemSchema %q is not one of %s, %s",
+			c.SystemSchema, AutoSystemSchema, strings.Join(names, ", ")))
+	}
+	for _, str := range c.Annotations {
+		if !validAnnotation(str) {
+			errs = append(errs, fmt.Errorf("annotations %q is not one of %s", str, annotationList()))
+		}
+	}
+	for _, str := range c.ObjectTypes {
+		if !validObjectType(str) {
+			errs = append(errs, fmt.Errorf("objectTypes %q is not one of %s",
+				str, strings.Join(objectTypeNames, ", ")))
+		}
+	}
+	if c.ProfileRows < 0 {
+		errs = append(errs, fmt.Errorf("profileRows %d is negative", c.ProfileRows))
+	}
+	if c.Parallelism < 0 {
+		errs = append(errs, fmt.Errorf("parallelism %d is negative", c.Parallelism))
+	}
+	if c.ProfileTimeout < 0 {
+		errs = append(errs, fmt.Errorf("profileTimeout %d is negative", c.ProfileTimeout))
+	}
+	if c.ClassificationRules != "" {
+		rules, err := LoadClassificationRules(c.ClassificationRules)
+		if err == nil {
+			_, err = NewClassifier(rules)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch c.OnError {
+	case "", OnErrorFail, OnErrorSkipTable, OnErrorKeepGoing:
+	default:
+		errs = append(errs, fmt.Errorf("onError %q is not one of %s", c.OnError,
+			strings.Join([]string{OnErrorFail, OnErrorSkipTable, OnErrorKeepGoing}, ", ")))
+	}
+	if _, err := NewLogger(io.Discard, c); err != nil {
+		errs = append(errs, err)
+	}
+	if GetWriter(c.Format) == nil {
+		errs = append(errs, fmt.Errorf("format %q is not one of %s",
+			c.Format, strings.Join(WriterNames(), ", ")))
+	}
+	if c.Security != "" && !strings.EqualFold(c.Security, "SSL") {
+		errs = append(errs, fmt.Errorf("security %q is not SSL", c.Security))
+	}
+	if c.SSLClientKeystoreDB != "" && c.SSLClientKeystash == "" &&
+		!hasOption(c.Options, "SSLClientKeystoreDBPassword") {
+		errs = append(errs, errors.New(
+			"sslClientKeystoredb requires sslClientKeystash or SSLClientKeystoreDBPassword option"))
+	}
+	switch strings.ToUpper(c.SSLClientHostnameValidation) {
+	case "", "BASIC", "OFF":
+	default:
+		errs = append(errs, fmt.Errorf("sslClientHostnameValidation %q is not Basic or Off",
+			c.SSLClientHostnameValidation))
+	}
+	for _, k := range []string{"HOSTNAME", "DATABASE", "PORT", "UID", "PWD", "Security",
+		"SSLServerCertificate", "SSLClientKeystoredb", "SSLClientKeystash",
+		"SSLClientHostnameValidation"} {
+		if hasOption(c.Options, k) {
+			errs = append(errs, fmt.Errorf("options %q duplicates a config field", k))
+		}
+	}
+	for _, path := range []string{c.SSLServerCertificate, c.SSLClientKeystoreDB, c.SSLClientKeystash} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, s := range c.TargetSchema {
+		if strings.ContainsRune(s, '\'') {
+			errs = append(errs, fmt.Errorf("targetSchema %q contains a quote", s))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hasOption は、CLI/ODBC キーワードが大文字小文字を区別せずに含まれるかを返します。
+func hasOption(options map[string]string, keyword string) bool {
+	for k := range options {
+		if strings.EqualFold(k, keyword) {
+			return true
+		}
+	}
+	return false
 }
 
 // Db2DSN は、Config から DSN を作ります。
@@ -31,6 +276,13 @@ func (c *Config) Db2DSN() *Db2DSN {
 		Port:     c.Port,
 		UserID:   c.UserID,
 		Password: c.Password,
+
+		Security:                    c.Security,
+		SSLServerCertificate:        c.SSLServerCertificate,
+		SSLClientKeystoreDB:         c.SSLClientKeystoreDB,
+		SSLClientKeystash:           c.SSLClientKeystash,
+		SSLClientHostnameValidation: c.SSLClientHostnameValidation,
+		Options:                     c.Options,
 	}
 }
 
@@ -38,23 +290,114 @@ func (c *Config) TargetSchemaInClause() string {
 	return fmt.Sprintf("('%s')", strings.Join(c.TargetSchema, "', '"))
 }
 
+// objectTypeNames は、Config.ObjectTypes に指定できる値です。
+// Alias, Nickname, Sequence は Db2 for LUW だけが対応しています。
+var objectTypeNames = []string{"Table", "View", "Alias", "Nickname", "Sequence", "Routine"}
+
+// defaultObjectTypes は、Config.ObjectTypes が空の場合に抽出するオブジェクトの種類です。
+var defaultObjectTypes = []string{"Table", "View", "Routine"}
+
+// validObjectType は、Config.ObjectTypes に指定できる値かどうかを返します。
+func validObjectType(str string) bool {
+	for _, name := range objectTypeNames {
+		if name == str {
+			return true
+		}
+	}
+	return false
+}
+
+// IncludesObjectType は、name の種類のオブジェクトを抽出するかどうかを返します。
+func (c *Config) IncludesObjectType(name string) bool {
+	types := c.ObjectTypes
+	if len(types) == 0 {
+		types = defaultObjectTypes
+	}
+	for _, str := range types {
+		if str == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectTypeInClause は、抽出するオブジェクトの種類に対応するカタログの TYPE の値を
+// SQL の IN 句の形式で返します。types は、オブジェクトの種類ごとの TYPE の値です。
+// 該当する値がない場合は、どの TYPE とも一致しない (”) を返します。
+func (c *Config) ObjectTypeInClause(types map[string][]string) string {
+	values := []string{}
+	for name, list := range types {
+		if c.IncludesObjectType(name) {
+			values = append(values, list...)
+		}
+	}
+	sort.Strings(values)
+	return fmt.Sprintf("('%s')", strings.Join(values, "', '"))
+}
+
 // Metadata は、テーブルのようなひとまとまりのデータに対するメタ情報です。
 type Metadata struct {
 	// Name は、メタデータ名です。
-	Name string
+	Name string `json:"name"`
 	// Alias は、メタデータの別名です。論理名を想定しています。
-	Alias string
+	Alias string `json:"alias"`
 	// FormalName は、メタデータの正式名（メタデータソース内で重複しない名前）です。
 	// 手入力のメタデータの場合は Name=FormalName です
-	FormalName string
+	FormalName string `json:"formalName"`
 	// Description は、説明です。
-	Description string
+	Description string `json:"description"`
 	// MetaType は、Metadata の種別です。
-	MetaType int
+	MetaType int `json:"metaType"`
 	// Lang は、ISO639-1 言語コードです。
-	Lang string
+	Lang string `json:"lang"`
 	// Columns は、Metadata を構成する Column です。【可変長】
-	Columns []Column
+	Columns []Column `json:"columns"`
+	// Constraints は、テーブルに定義された主キー、一意キー、外部キーです。
+	Constraints []Constraint `json:"constraints,omitempty"`
+	// Indexes は、テーブルに定義された索引です。
+	Indexes []Index `json:"indexes,omitempty"`
+	// View は、ビューの定義です。ビューでなければ nil です。
+	View *View `json:"view,omitempty"`
+	// Routine は、ストアドプロシージャー、ユーザー定義関数の属性です。
+	// MetaType が Model の場合に設定し、Columns はパラメーターです。
+	Routine *Routine `json:"routine,omitempty"`
+	// BaseObject は、別名(ALIAS)が参照するテーブル、ビューなどの正式名です。
+	BaseObject string `json:"baseObject,omitempty"`
+	// Remote は、ニックネームが参照するリモートのオブジェクトです。
+	Remote *RemoteObject `json:"remote,omitempty"`
+	// Sequence は、シーケンスの属性です。MetaType が Model の場合に設定します。
+	Sequence *Sequence `json:"sequence,omitempty"`
+	// Statistics は、テーブルの統計情報です。Config.Statistics が真の場合に設定します。
+	Statistics *TableStatistics `json:"statistics,omitempty"`
+}
+
+// SetConstraints は、Constraints を保持し、各 Column の KeyType を設定します。
+// 複数の制約に含まれるカラムは、主キー、一意キー、外部キーの順に優先します。
+func (m *Metadata) SetConstraints(list []Constraint) {
+	m.Constraints = list
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		col.KeyType = KeyType{}
+		for _, c := range list {
+			for j, name := range c.Columns {
+				if name != col.Name {
+					continue
+				}
+				if col.KeyType.Constraint == 0 || c.Type < col.KeyType.Constraint {
+					col.KeyType = KeyType{Constraint: c.Type, Order: j + 1}
+				}
+			}
+		}
+	}
+}
+
+// SetView は、ビューの定義、既定のスキーマ、参照するテーブルを保持します。
+// 定義がない(ビューではない)場合は何もしません。
+func (m *Metadata) SetView(definition string, qualifier string, baseTables []string) {
+	if definition == "" {
+		return
+	}
+	m.View = &View{Definition: definition, Qualifier: qualifier, BaseTables: baseTables}
 }
 
 // MetaTypeName は、MetaType の文字列表現を返す
@@ -74,8 +417,10 @@ func (m Metadata) MetaTypeName() string {
 }
 
 // ToCSVString は、Metadata の CSV 表現を返す
+// カンマ、ダブルクォート、改行を含む値は RFC 4180 に従って " で囲みます。
 func (m Metadata) ToCSVString() string {
 	buf := strings.Builder{}
+	w := csv.NewWriter(&buf)
 	// 20: メタデータ
 	//   - Type*:       データタイプ
 	//   - ID*:         メタデータID。IDを空にしてインポートしたときはメタデータを新規登録します。同じ名前のメタデータは新規登録できません。
@@ -90,13 +435,13 @@ func (m Metadata) ToCSVString() string {
 	//     - Model:       モデル形式のメタデータ
 	//     - Stream:      ストリーム形式のメタデータ
 	//     - File:        ファイル形式のメタデータ
-	buf.WriteString(fmt.Sprintf("20,,%s,%s,%s,%s,%s\n",
+	w.Write([]string{"20", "",
 		m.FormalName,
 		m.Alias,
 		m.Description,
 		m.Lang,
 		m.MetaTypeName(),
-	))
+	})
 	for _, c := range m.Columns {
 		// 30: メタデータのカラム
 		//   - Type*:       データタイプ
@@ -111,15 +456,17 @@ func (m Metadata) ToCSVString() string {
 		//     - Repeated:    複数
 		//   - Constraint:  キーの制約
 		//     - Primary:     主キー
-		buf.WriteString(fmt.Sprintf("30,,%s,%s,%s,%s,%s,%s\n",
+		w.Write([]string{"30", "",
 			c.Name,
 			c.Alias,
 			c.Description,
-			c.Type,
+			c.SQLType(),
 			c.ModeName(),
 			c.KeyType.ConstraintName(),
-		))
+		})
 	}
+	// strings.Builder への書き込みは失敗しないため、エラーは確認しません
+	w.Flush()
 	buf.WriteString("\n")
 	return buf.String()
 }
@@ -127,19 +474,52 @@ func (m Metadata) ToCSVString() string {
 // Column は、データ項目のメタ情報です。
 type Column struct {
 	// Name は、カラム名です。
-	Name string
+	Name string `json:"name"`
 	// Alias は、カラムの別名です。論理名を想定しています。
-	Alias string
+	Alias string `json:"alias"`
 	// Description は、説明です。
-	Description string
+	Description string `json:"description"`
 	// Type は、Column のデータ型です。
-	Type string
+	Type string `json:"type"`
+	// Length は、文字列、バイナリの最大長です。
+	Length int `json:"length,omitempty"`
+	// Precision は、10 進数の精度、またはタイムスタンプの秒の小数部の桁数です。
+	Precision int `json:"precision,omitempty"`
+	// Scale は、10 進数の位取りです。
+	Scale int `json:"scale,omitempty"`
+	// CCSID は、文字列の CCSID(Db2 for LUW はコードページ)です。
+	CCSID int `json:"ccsid,omitempty"`
+	// ForBitData は、文字列が FOR BIT DATA かどうかです。
+	ForBitData bool `json:"forBitData,omitempty"`
+	// Default は、カラムの既定値の式です。
+	Default string `json:"default,omitempty"`
+	// Identity は、識別カラムの属性です。識別カラムでなければ nil です。
+	Identity *Identity `json:"identity,omitempty"`
+	// Generated は、生成カラムの属性です。生成カラムでなければ nil です。
+	Generated *Generated `json:"generated,omitempty"`
+	// Lineage は、ビューのカラムの元になった実表のカラムです。
+	Lineage []ColumnSource `json:"lineage,omitempty"`
+	// Statistics は、カタログに記録されたカラムの統計情報です。
+	Statistics *ColumnStatistics `json:"statistics,omitempty"`
+	// Profile は、標本のデータから作ったカラムのプロファイルです。
+	Profile *ColumnProfile `json:"profile,omitempty"`
+	// Classification は、個人情報などの分類です。分類されなかったカラムは nil です。
+	Classification *Classification `json:"classification,omitempty"`
+	// Parameter は、ルーチンのパラメーターの種別(IN, OUT, INOUT, RETURN)です。
+	// テーブルのカラムは空です。
+	Parameter string `json:"parameter,omitempty"`
 	// Mode は、Column の多重度の種別です。
-	Mode int
+	Mode int `json:"mode"`
 	// Order は、DB に設定されたカラムの順番(1 スタート)
-	Order int
+	Order int `json:"order"`
 	// KeyType は、カラムに設定されたキーのタイプ
-	KeyType KeyType
+	KeyType KeyType `json:"keyType"`
+
+	// samples は、プロファイルの標本の異なる値です。分類に使い、出力しません。
+	samples []string
+	// hasPrecision は、カタログにタイムスタンプの秒の小数部の桁数があったかどうかです。
+	// 桁数が 0 の場合と、不明な場合を区別します。
+	hasPrecision bool
 }
 
 // ModeName は、Mode の文字列表現を返す
@@ -156,6 +536,194 @@ func (c Column) ModeName() string {
 	return str
 }
 
+// Identity は、識別カラムの属性です。
+type Identity struct {
+	// Generation は、ALWAYS または BY DEFAULT
+	Generation string `json:"generation"`
+	// Start は、開始値
+	Start string `json:"start,omitempty"`
+	// Increment は、増分
+	Increment string `json:"increment,omitempty"`
+}
+
+// Generated は、GENERATED 句で値を生成するカラムの属性です。
+type Generated struct {
+	// Generation は、ALWAYS または BY DEFAULT
+	Generation string `json:"generation"`
+	// Expression は、AS に続く式(ROW CHANGE TIMESTAMP など)。ROWID は空です。
+	Expression string `json:"expression,omitempty"`
+}
+
+// parameterMode は、カタログのパラメーターの行の種別を IN, OUT, INOUT, RETURN に
+// 変換します。キャスト前の戻り値(R)などは空文字列です。
+func parameterMode(rowType string) string {
+	switch strings.TrimSpace(rowType) {
+	case "P":
+		return "IN"
+	case "O":
+		return "OUT"
+	case "B":
+		return "INOUT"
+	case "C":
+		return "RETURN"
+	}
+	return ""
+}
+
+// generation は、カタログの生成の種別(A, D)を ALWAYS, BY DEFAULT に変換します。
+func generation(str string) string {
+	switch strings.TrimSpace(str) {
+	case "A":
+		return "ALWAYS"
+	case "D":
+		return "BY DEFAULT"
+	}
+	return ""
+}
+
+// GenerationClause は、識別カラムと生成カラムの GENERATED 句を返す
+// どちらでもない場合は空文字列を返す
+func (c Column) GenerationClause() string {
+	switch {
+	case c.Identity != nil:
+		str := fmt.Sprintf("GENERATED %s AS IDENTITY", c.Identity.Generation)
+		options := []string{}
+		if c.Identity.Start != "" {
+			options = append(options, "START WITH "+c.Identity.Start)
+		}
+		if c.Identity.Increment != "" {
+			options = append(options, "INCREMENT BY "+c.Identity.Increment)
+		}
+		if len(options) > 0 {
+			str += " (" + strings.Join(options, ", ") + ")"
+		}
+		return str
+	case c.Generated != nil:
+		str := "GENERATED " + c.Generated.Generation
+		if c.Generated.Expression != "" {
+			str += " AS " + c.Generated.Expression
+		}
+		return str
+	}
+	return ""
+}
+
+// 型の種類です。長さ、精度、位取りの扱いが異なります。
+const (
+	typeKindOther     = iota
+	typeKindCharacter // FOR BIT DATA を指定できる文字列
+	typeKindString    // その他の文字列、バイナリ
+	typeKindDecimal
+	typeKindTimestamp
+)
+
+// typeKinds は、カタログに現れる組み込み型の名前と種類です。
+// Db2 for i と Db2 for z/OS の省略形(VARG, TIMESTMP など)も含みます。
+var typeKinds = map[string]int{
+	"CHAR":            typeKindCharacter,
+	"CHARACTER":       typeKindCharacter,
+	"VARCHAR":         typeKindCharacter,
+	"LONG VARCHAR":    typeKindCharacter,
+	"LONGVAR":         typeKindCharacter,
+	"GRAPHIC":         typeKindString,
+	"VARGRAPHIC":      typeKindString,
+	"VARG":            typeKindString,
+	"LONG VARGRAPHIC": typeKindString,
+	"LONGVARG":        typeKindString,
+	"NCHAR":           typeKindString,
+	"NVARCHAR":        typeKindString,
+	"CLOB":            typeKindString,
+	"DBCLOB":          typeKindString,
+	"NCLOB":           typeKindString,
+	"BLOB":            typeKindString,
+	"BINARY":          typeKindString,
+	"VARBINARY":       typeKindString,
+	"VARBIN":          typeKindString,
+	"DECIMAL":         typeKindDecimal,
+	"NUMERIC":         typeKindDecimal,
+	"TIMESTAMP":       typeKindTimestamp,
+	"TIMESTMP":        typeKindTimestamp,
+	"TIMESTZ":         typeKindTimestamp,
+}
+
+// typeNames は、Db2 for i と Db2 for z/OS のカタログの省略形と、SQL の型の名前です。
+var typeNames = map[string]string{
+	"LONGVAR":  "LONG VARCHAR",
+	"VARG":     "VARGRAPHIC",
+	"LONGVARG": "LONG VARGRAPHIC",
+	"VARBIN":   "VARBINARY",
+	"TIMESTMP": "TIMESTAMP",
+	"TIMESTZ":  "TIMESTAMP WITH TIME ZONE",
+}
+
+// typeKind は、型の種類を返します。SYSIBM 以外のスキーマの型(ユーザー定義型)は
+// typeKindOther です。
+func typeKind(typ string) int {
+	typ = strings.TrimPrefix(typ, "SYSIBM.")
+	if strings.Contains(typ, ".") {
+		return typeKindOther
+	}
+	return typeKinds[typ]
+}
+
+// SetSize は、カタログの長さと位取りを型の種類に応じて Length, Precision, Scale に
+// 設定します。10 進数の長さは精度、タイムスタンプの位取りは秒の小数部の桁数です。
+// 位取りが NULL の場合は、scale に -1 を渡します。
+func (c *Column) SetSize(length int, scale int) {
+	switch typeKind(c.Type) {
+	case typeKindCharacter, typeKindString:
+		c.Length = length
+	case typeKindDecimal:
+		c.Precision = length
+		if scale > 0 {
+			c.Scale = scale
+		}
+	case typeKindTimestamp:
+		if scale >= 0 {
+			c.Precision = scale
+			c.hasPrecision = true
+		}
+	}
+}
+
+// catalogScale は、カタログの位取りの値を SetSize に渡す値にします。NULL や数値でない
+// 場合は -1 です。
+func catalogScale(v string) int {
+	scale, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+	return scale
+}
+
+// SQLType は、長さ、精度、位取りを含めた SQL の型の表現(DECIMAL(11,2) など)を返す
+// 組み込み型のスキーマ SYSIBM は省略し、カタログの省略形は SQL の型の名前にします。
+// タイムスタンプの秒の小数部の桁数が不明な場合は、桁数を付けません。
+func (c Column) SQLType() string {
+	typ := strings.TrimPrefix(c.Type, "SYSIBM.")
+	if name, ok := typeNames[typ]; ok {
+		typ = name
+	}
+	switch typeKind(c.Type) {
+	case typeKindCharacter, typeKindString:
+		if c.Length > 0 {
+			typ = fmt.Sprintf("%s(%d)", typ, c.Length)
+		}
+		if c.ForBitData {
+			typ += " FOR BIT DATA"
+		}
+	case typeKindDecimal:
+		if c.Precision > 0 {
+			typ = fmt.Sprintf("%s(%d,%d)", typ, c.Precision, c.Scale)
+		}
+	case typeKindTimestamp:
+		if c.Precision > 0 || c.hasPrecision {
+			typ = strings.Replace(typ, "TIMESTAMP", fmt.Sprintf("TIMESTAMP(%d)", c.Precision), 1)
+		}
+	}
+	return typ
+}
+
 // KeyType は、カラムに設定されたキーのタイプ
 type KeyType struct {
 	// Constraint は、キーの制約(Primary, Unique など)
@@ -165,6 +733,8 @@ type KeyType struct {
 }
 
 // ConstraintName は、Constraint の文字列表現を返す
+// Mashu CSV の Constraint は主キーだけのため、一意キーと外部キーは空です。
+// 一意キーと外部キーは、Metadata.Constraints と Keys の注記で出力します。
 func (k KeyType) ConstraintName() string {
 	str := ""
 	switch k.Constraint {
@@ -173,3 +743,276 @@ func (k KeyType) ConstraintName() string {
 	}
 	return str
 }
+
+// hasPrimaryKey は、主キーの制約を含むかどうかを返します。
+func hasPrimaryKey(list []Constraint) bool {
+	for _, c := range list {
+		if c.Type == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Constraint は、テーブルに定義されたキー制約です。
+type Constraint struct {
+	// Name は、制約名です。
+	Name string `json:"name"`
+	// Type は、制約の種別(KeyType.Constraint と同じ値)
+	Type int `json:"type"`
+	// Columns は、キーを構成するカラム名(キーの順)
+	Columns []string `json:"columns"`
+	// RefTable は、外部キーが参照するテーブルの正式名
+	RefTable string `json:"refTable,omitempty"`
+	// RefColumns は、外部キーが参照するカラム名(Columns と同じ順)
+	RefColumns []string `json:"refColumns,omitempty"`
+}
+
+// TypeName は、Type の文字列表現を返す
+func (c Constraint) TypeName() string {
+	str := ""
+	switch c.Type {
+	case 1:
+		return "Primary"
+	case 2:
+		return "Unique"
+	case 3:
+		return "Foreign"
+	}
+	return str
+}
+
+// Index は、テーブルに定義された索引です。
+type Index struct {
+	// Schema は、索引のスキーマです。
+	Schema string `json:"schema"`
+	// Name は、索引名です。
+	Name string `json:"name"`
+	// Unique は、一意索引(主キー、一意キーを強制する索引を含む)かどうか
+	Unique bool `json:"unique"`
+	// Clustered は、クラスター索引かどうか
+	Clustered bool `json:"clustered"`
+	// Columns は、キーを構成するカラム(キーの順)
+	Columns []IndexColumn `json:"columns"`
+	// Include は、キーに含まれない INCLUDE カラム名
+	Include []string `json:"include,omitempty"`
+}
+
+// IndexColumn は、索引のキーを構成するカラムです。
+type IndexColumn struct {
+	// Name は、カラム名です。
+	Name string `json:"name"`
+	// Order は、ASC または DESC
+	Order string `json:"order"`
+}
+
+// String は、カラム名と順序の文字列表現を返す
+func (c IndexColumn) String() string {
+	return c.Name + " " + c.Order
+}
+
+// indexOrder は、カタログの順序(A, D)を ASC, DESC に変換します。
+func indexOrder(str string) string {
+	if strings.TrimSpace(str) == "D" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// View は、ビューの定義です。
+type View struct {
+	// Definition は、カタログに記録されたビューの SQL 文です。
+	Definition string `json:"definition"`
+	// Qualifier は、ビューを作成したときの既定のスキーマです。定義の中の修飾されて
+	// いないテーブル名は、このスキーマのテーブルです。カタログにない場合は空です。
+	Qualifier string `json:"qualifier,omitempty"`
+	// BaseTables は、ビューが参照するテーブル、ビューの正式名
+	BaseTables []string `json:"baseTables,omitempty"`
+}
+
+// Routine は、ストアドプロシージャー、ユーザー定義関数の属性です。
+type Routine struct {
+	// Type は、PROCEDURE または FUNCTION
+	Type string `json:"type"`
+	// SpecificName は、多重定義されたルーチンを区別する特定名
+	SpecificName string `json:"specificName"`
+	// Language は、ルーチンの本体の言語(SQL, C, JAVA など)
+	Language string `json:"language,omitempty"`
+	// Definition は、カタログに記録された SQL ルーチンの CREATE 文、または本体です。
+	Definition string `json:"definition,omitempty"`
+}
+
+// routineType は、カタログのルーチンの種別(F, P)を FUNCTION, PROCEDURE に変換します。
+func routineType(str string) string {
+	switch strings.TrimSpace(str) {
+	case "F", "FUNCTION":
+		return "FUNCTION"
+	case "P", "PROCEDURE":
+		return "PROCEDURE"
+	}
+	return ""
+}
+
+// RemoteObject は、フェデレーションのニックネームが参照するリモートのオブジェクトです。
+type RemoteObject struct {
+	// Server は、サーバー名
+	Server string `json:"server"`
+	// Schema は、リモートのスキーマ名
+	Schema string `json:"schema"`
+	// Name は、リモートのオブジェクト名
+	Name string `json:"name"`
+	// Type は、リモートのオブジェクトの種類(T: テーブル, V: ビュー など)
+	Type string `json:"type,omitempty"`
+}
+
+// String は、サーバー名.スキーマ名.オブジェクト名 を返す
+func (r RemoteObject) String() string {
+	return r.Server + "." + r.Schema + "." + r.Name
+}
+
+// Sequence は、シーケンスの属性です。値は、カタログの文字列表現のままです。
+type Sequence struct {
+	// DataType は、データ型
+	DataType string `json:"dataType"`
+	// Start は、開始値
+	Start string `json:"start"`
+	// Increment は、増分
+	Increment string `json:"increment"`
+	// MinValue は、最小値
+	MinValue string `json:"minValue"`
+	// MaxValue は、最大値
+	MaxValue string `json:"maxValue"`
+	// Cycle は、最大値(最小値)の後に循環するかどうか
+	Cycle bool `json:"cycle"`
+	// Cache は、キャッシュする値の数。0 は NO CACHE です。
+	Cache int `json:"cache"`
+}
+
+// Clause は、CREATE SEQUENCE 文の AS 以降の属性を返す
+func (s Sequence) Clause() string {
+	str := fmt.Sprintf("AS %s START WITH %s INCREMENT BY %s MINVALUE %s MAXVALUE %s",
+		strings.TrimPrefix(s.DataType, "SYSIBM."), s.Start, s.Increment, s.MinValue, s.MaxValue)
+	if s.Cycle {
+		str += " CYCLE"
+	} else {
+		str += " NO CYCLE"
+	}
+	if s.Cache > 0 {
+		str += fmt.Sprintf(" CACHE %d", s.Cache)
+	} else {
+		str += " NO CACHE"
+	}
+	return str
+}
+
+// TableStatistics は、カタログに記録されたテーブルの統計情報と格納の情報です。
+// プラットフォームのカタログにない値は、ゼロ値(件数とページ数は -1)です。
+type TableStatistics struct {
+	// RowCount は、行数です。統計情報が収集されていない場合は -1 です。
+	RowCount int64 `json:"rowCount"`
+	// Pages は、データのページ数です。統計情報が収集されていない場合は -1 です。
+	Pages int64 `json:"pages"`
+	// Size は、データのバイト数です。
+	Size int64 `json:"size,omitempty"`
+	// StatsTime は、統計情報を収集(RUNSTATS)した日時です。
+	StatsTime string `json:"statsTime,omitempty"`
+	// LastChanged は、データを最後に変更した日時です。
+	LastChanged string `json:"lastChanged,omitempty"`
+	// Tablespace は、テーブルスペースの名前です。
+	Tablespace string `json:"tablespace,omitempty"`
+	// Compression は、圧縮の種類(ROW ADAPTIVE, VALUE など)です。
+	Compression string `json:"compression,omitempty"`
+	// Partitions は、パーティション(メンバー)の数です。
+	Partitions int `json:"partitions,omitempty"`
+	// PartitionKeys は、パーティション・キー(分散キー)のカラムです。
+	PartitionKeys []string `json:"partitionKeys,omitempty"`
+}
+
+// newTableStatistics は、件数とページ数が不明な TableStatistics を作ります。
+func newTableStatistics() *TableStatistics {
+	return &TableStatistics{RowCount: -1, Pages: -1}
+}
+
+// Notes は、統計情報の要約を 1 項目 1 行で返します。
+// 行数が 0 のテーブルと統計情報がないテーブルは、その旨を付記します。
+func (s TableStatistics) Notes() []string {
+	notes := []string{}
+	switch {
+	case s.RowCount < 0:
+		notes = append(notes, "Rows: unknown (no statistics)")
+	case s.RowCount == 0:
+		notes = append(notes, "Rows: 0 (empty)")
+	default:
+		notes = append(notes, fmt.Sprintf("Rows: %d", s.RowCount))
+	}
+	if s.Pages >= 0 {
+		notes = append(notes, fmt.Sprintf("Pages: %d", s.Pages))
+	}
+	if s.Size > 0 {
+		notes = append(notes, fmt.Sprintf("Size: %d bytes", s.Size))
+	}
+	for _, kv := range [][2]string{
+		{"Statistics Time", s.StatsTime},
+		{"Last Changed", s.LastChanged},
+		{"Tablespace", s.Tablespace},
+		{"Compression", s.Compression},
+	} {
+		if kv[1] != "" {
+			notes = append(notes, kv[0]+": "+kv[1])
+		}
+	}
+	if s.Partitions > 0 {
+		notes = append(notes, fmt.Sprintf("Partitions: %d", s.Partitions))
+	}
+	if len(s.PartitionKeys) > 0 {
+		notes = append(notes, "Partition Keys: "+strings.Join(s.PartitionKeys, ", "))
+	}
+	return notes
+}
+
+// ColumnStatistics は、カタログに記録されたカラムの統計情報です。
+type ColumnStatistics struct {
+	// Distinct は、異なる値の数です。統計情報が収集されていない場合は -1 です。
+	Distinct int64 `json:"distinct"`
+	// Nulls は、NULL の数です。統計情報が収集されていない場合は -1 です。
+	Nulls int64 `json:"nulls"`
+	// High は、2 番目に大きい値です。
+	High string `json:"high,omitempty"`
+	// Low は、2 番目に小さい値です。
+	Low string `json:"low,omitempty"`
+	// AvgLength は、平均の長さ(バイト数)です。
+	AvgLength int `json:"avgLength,omitempty"`
+}
+
+// newColumnStatistics は、異なる値の数と NULL の数が不明な ColumnStatistics を作ります。
+func newColumnStatistics() *ColumnStatistics {
+	return &ColumnStatistics{Distinct: -1, Nulls: -1}
+}
+
+// Notes は、統計情報の要約を 1 項目 1 行で返します。統計情報がない項目は省略します。
+func (s ColumnStatistics) Notes() []string {
+	notes := []string{}
+	if s.Distinct >= 0 {
+		notes = append(notes, fmt.Sprintf("Distinct: %d", s.Distinct))
+	}
+	if s.Nulls >= 0 {
+		notes = append(notes, fmt.Sprintf("Nulls: %d", s.Nulls))
+	}
+	if s.Low != "" || s.High != "" {
+		notes = append(notes, fmt.Sprintf("Range: %s - %s", s.Low, s.High))
+	}
+	if s.AvgLength > 0 {
+		notes = append(notes, fmt.Sprintf("Average Length: %d", s.AvgLength))
+	}
+	return notes
+}
+
+// statsTime は、カタログの統計情報の日時を返します。収集されていないことを表す
+// NULL や 0001-01-01 は空文字列にします。
+func statsTime(str string) string {
+	str = strings.TrimSpace(str)
+	if strings.HasPrefix(str, "0001-01-01") {
+		return ""
+	}
+	return str
+}
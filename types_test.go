@@ -0,0 +1,203 @@
+// Copyright © 2024 ROBON Inc. All rights reserved.
+// This software is licensed under PolyForm Shield License 1.0.0
+// https://polyformproject.org/licenses/shield/1.0.0/
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseMashuCSV は、Mashu CSV を Metadata に戻します。
+func parseMashuCSV(t *testing.T, str string) []Metadata {
+	t.Helper()
+	r := csv.NewReader(strings.NewReader(str))
+	r.FieldsPerRecord = -1
+
+	metaTypes := map[string]int{"Table": 1, "Model": 2, "Stream": 3, "File": 4}
+	modes := map[string]int{"Nullable": 0, "Required": 1, "Repeated": 2}
+	constraints := map[string]int{"": 0, "Primary": 1}
+
+	result := []Metadata{}
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("csv.Reader.Read() error :%s", err)
+		}
+		switch rec[0] {
+		case "20":
+			if len(rec) != 7 {
+				t.Fatalf("type 20 has %d fields: %#v", len(rec), rec)
+			}
+			result = append(result, Metadata{
+				Name:        rec[2][strings.LastIndex(rec[2], ".")+1:],
+				FormalName:  rec[2],
+				Alias:       rec[3],
+				Description: rec[4],
+				Lang:        rec[5],
+				MetaType:    metaTypes[rec[6]],
+			})
+		case "30":
+			if len(rec) != 8 {
+				t.Fatalf("type 30 has %d fields: %#v", len(rec), rec)
+			}
+			meta := &result[len(result)-1]
+			meta.Columns = append(meta.Columns, Column{
+				Name:        rec[2],
+				Alias:       rec[3],
+				Description: rec[4],
+				Type:        rec[5],
+				Mode:        modes[rec[6]],
+				KeyType:     KeyType{Constraint: constraints[rec[7]]},
+			})
+		default:
+			t.Fatalf("unknown record type: %#v", rec)
+		}
+	}
+	return result
+}
+
+func TestMetadataToCSVString(t *testing.T) {
+	want := []Metadata{
+		{
+			Name:        "EMPLOYEE",
+			FormalName:  "DB2INST1.EMPLOYEE",
+			Alias:       "従業員",
+			Description: "氏名, 所属部門を保持する\n\"社外秘\"",
+			MetaType:    1,
+			Lang:        "ja",
+			Columns: []Column{
+				{
+					Name:        "EMPNO",
+					Alias:       "社員番号",
+					Description: "社員番号",
+					Type:        "CHARACTER",
+					Mode:        1,
+					KeyType:     KeyType{Constraint: 1},
+				},
+				{
+					Name:        "SALARY",
+					Alias:       "給与, 月額",
+					Description: "給与\r\n(月額, \"円\")",
+					Type:        "DECIMAL",
+					Mode:        0,
+				},
+			},
+		},
+		{
+			Name:       "DEPARTMENT",
+			FormalName: "DB2INST1.DEPARTMENT",
+			Alias:      " 部門 ",
+			MetaType:   1,
+			Lang:       "ja",
+			Columns: []Column{
+				{Name: "DEPTNO", Type: "CHARACTER", Mode: 1},
+			},
+		},
+	}
+
+	buf := strings.Builder{}
+	for _, m := range want {
+		buf.WriteString(m.ToCSVString())
+	}
+	got := parseMashuCSV(t, buf.String())
+
+	// encoding/csv は、クォート内の \r\n を \n として読み込みます
+	want[0].Columns[1].Description = "給与\n(月額, \"円\")"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestColumnSQLType(t *testing.T) {
+	tests := []struct {
+		typ    string
+		length int
+		scale  int
+		bit    bool
+		want   string
+	}{
+		{"SYSIBM.VARCHAR", 254, 0, false, "VARCHAR(254)"},
+		{"CHAR", 8, 0, true, "CHAR(8) FOR BIT DATA"},
+		{"DECIMAL", 11, 2, false, "DECIMAL(11,2)"},
+		{"NUMERIC", 5, 0, false, "NUMERIC(5,0)"},
+		{"SYSIBM.TIMESTAMP", 10, 6, false, "TIMESTAMP(6)"},
+		{"INTEGER", 4, 0, false, "INTEGER"},
+		{"VARG", 20, 0, false, "VARGRAPHIC(20)"},
+		{"SYSIBM.VARBIN", 32, 0, false, "VARBINARY(32)"},
+		{"LONGVAR", 32700, 0, false, "LONG VARCHAR(32700)"},
+		{"LONGVARG", 16350, 0, false, "LONG VARGRAPHIC(16350)"},
+		{"TIMESTMP", 10, 6, false, "TIMESTAMP(6)"},
+		{"TIMESTAMP", 10, 0, false, "TIMESTAMP(0)"},
+		{"TIMESTAMP", 10, -1, false, "TIMESTAMP"},
+		{"TIMESTZ", 13, 12, false, "TIMESTAMP(12) WITH TIME ZONE"},
+		{"MYSCHEMA.MONEY", 9, 2, false, "MYSCHEMA.MONEY"},
+	}
+	for _, tt := range tests {
+		col := Column{Type: tt.typ, ForBitData: tt.bit}
+		col.SetSize(tt.length, tt.scale)
+		if got := col.SQLType(); got != tt.want {
+			t.Errorf("Column{Type: %s}.SQLType() = %s, want %s", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestConfigObjectTypeInClause(t *testing.T) {
+	tests := []struct {
+		objectTypes []string
+		want        string
+	}{
+		{nil, "('S', 'T', 'U', 'V', 'W')"},
+		{[]string{"View", "Nickname"}, "('N', 'V', 'W')"},
+		{[]string{"Sequence"}, "('')"},
+	}
+	for _, tt := range tests {
+		config := &Config{ObjectTypes: tt.objectTypes}
+		if got := config.ObjectTypeInClause(db2ObjectTypes); got != tt.want {
+			t.Errorf("ObjectTypeInClause(%v) = %s, want %s", tt.objectTypes, got, tt.want)
+		}
+	}
+}
+
+func TestTableStatisticsNotes(t *testing.T) {
+	tests := []struct {
+		stats TableStatistics
+		want  []string
+	}{
+		{*newTableStatistics(), []string{"Rows: unknown (no statistics)"}},
+		{TableStatistics{RowCount: 0, Pages: 0}, []string{"Rows: 0 (empty)", "Pages: 0"}},
+		{TableStatistics{RowCount: 42, Pages: -1, Size: 65536, LastChanged: "2024-04-03",
+			Partitions: 2, PartitionKeys: []string{"EMPNO", "WORKDEPT"}},
+			[]string{"Rows: 42", "Size: 65536 bytes", "Last Changed: 2024-04-03",
+				"Partitions: 2", "Partition Keys: EMPNO, WORKDEPT"}},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.Notes(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Notes() = %#v, want %#v", got, tt.want)
+		}
+	}
+}
+
+func TestColumnStatisticsNotes(t *testing.T) {
+	tests := []struct {
+		stats ColumnStatistics
+		want  []string
+	}{
+		{*newColumnStatistics(), []string{}},
+		{ColumnStatistics{Distinct: 8, Nulls: 1, High: "'E11'", Low: "'B01'", AvgLength: 4},
+			[]string{"Distinct: 8", "Nulls: 1", "Range: 'B01' - 'E11'", "Average Length: 4"}},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.Notes(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Notes() = %#v, want %#v", got, tt.want)
+		}
+	}
+}